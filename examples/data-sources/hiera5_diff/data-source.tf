@@ -0,0 +1,17 @@
+data "hiera5_diff" "promotion" {
+  keys      = ["aws_instance_size", "java_opts"]
+  namespace = "aws_tags"
+  from = {
+    "environment" = "stage"
+  }
+  to = {
+    "environment" = "live"
+  }
+}
+
+check "promotion" {
+  assert {
+    condition     = length([for c in data.hiera5_diff.promotion.changes : c if c.type == "removed"]) == 0
+    error_message = "Promoting stage to live removes values"
+  }
+}
@@ -0,0 +1,11 @@
+data "hiera5_matrix" "aws_instance_size" {
+  key = "aws_instance_size"
+  matrix = {
+    "service"     = ["api", "worker"]
+    "environment" = ["live", "stage"]
+  }
+}
+
+output "live_api_instance_size" {
+  value = data.hiera5_matrix.aws_instance_size.value["environment=live,service=api"]
+}
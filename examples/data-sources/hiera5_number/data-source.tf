@@ -0,0 +1,6 @@
+data "hiera5_number" "max_connections" {
+  key          = "max_connections"
+  integer_only = true
+  min          = 1
+  max          = 1000
+}
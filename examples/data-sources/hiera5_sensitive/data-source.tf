@@ -0,0 +1,3 @@
+data "hiera5_sensitive" "db_password" {
+  key = "db_password"
+}
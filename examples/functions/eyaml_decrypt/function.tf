@@ -0,0 +1,4 @@
+output "db_password" {
+  value     = sensitive(provider::hiera5::eyaml_decrypt(local.encrypted_db_password, "keys/private_key.pkcs7.pem"))
+  sensitive = true
+}
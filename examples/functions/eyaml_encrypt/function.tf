@@ -0,0 +1,3 @@
+output "db_password" {
+  value = provider::hiera5::eyaml_encrypt(random_password.db.result, "keys/public_key.pkcs7.pem")
+}
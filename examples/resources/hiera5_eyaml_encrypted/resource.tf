@@ -0,0 +1,4 @@
+resource "hiera5_eyaml_encrypted" "db_password" {
+  plaintext  = random_password.db.result
+  public_key = "keys/public_key.pkcs7.pem"
+}
@@ -2,15 +2,24 @@ package hiera5
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/lyraproj/dgo/dgo"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
 var (
 	idAttribute = schema.StringAttribute{
-		Computed: true,
+		Computed:    true,
+		Description: "Identifier derived from the key, scope, merge strategy and config file the value is looked up with.",
 	}
 
 	keyAttribute = schema.StringAttribute{
@@ -30,8 +39,49 @@ var (
 		Optional:    true,
 	}
 
+	schemaAttribute = schema.StringAttribute{
+		Description: "JSON Schema, given either inline or as a path to a schema file, the looked up value must validate against. Validation failures are reported even if a default value is set.",
+		Optional:    true,
+	}
+
+	sourceAttributeTypes = map[string]attr.Type{
+		"level": types.StringType,
+		"path":  types.StringType,
+	}
+
+	sourcesAttribute = schema.ListNestedAttribute{
+		Computed:    true,
+		Description: "The hierarchy levels, and the data files within them, that contributed to the value. Empty when the default value is used.",
+		NestedObject: schema.NestedAttributeObject{
+			Attributes: map[string]schema.Attribute{
+				"level": schema.StringAttribute{
+					Computed:    true,
+					Description: "The name of the hierarchy level.",
+				},
+				"path": schema.StringAttribute{
+					Computed:    true,
+					Description: "The data file the value was found in, relative to the hiera config file when located below it.",
+				},
+			},
+		},
+	}
+
+	sourceHashAttribute = schema.StringAttribute{
+		Computed:    true,
+		Description: "SHA-256 digest of the data files listed in `sources`. It changes whenever one of them does, which makes it suitable for `replace_triggered_by`. Empty when the default value is used.",
+	}
+
+	elementTypeAttribute = schema.StringAttribute{
+		Optional:    true,
+		Description: "The type of the elements of `typed_value`, one of `string`, `number`, `bool` or `dynamic`. Only `dynamic` keeps nested arrays and hashes, which are returned as tuples and objects. Default: dynamic",
+	}
+
 	valueDescription   = "The result of the lookup in the hiera data, or the default value if the key is not found."
 	defaultDescription = "Default value to return if the value isn't found in the hiera data."
+
+	stringElementsDescription = " Elements are strings, nested arrays and hashes being JSON encoded; see `typed_value` for other types."
+	typedValueDescription     = "The result of the lookup in the hiera data, or the default value if the key is not found, whose elements are of `element_type`."
+	typedDefaultDescription   = "Default value to return in `typed_value` if the value isn't found in the hiera data, which may hold nested values unlike `default`. Conflicts with `default`."
 )
 
 func processScopeOverrideAttribute(ctx context.Context, rawScope types.Map) (map[string]interface{}, []diag.Diagnostic) {
@@ -49,3 +99,208 @@ func processScopeOverrideAttribute(ctx context.Context, rawScope types.Map) (map
 
 	return scopeOverride, diag
 }
+
+func processElementType(elementType types.String) (string, []diag.Diagnostic) {
+	if elementType.IsNull() {
+		return "dynamic", nil
+	}
+
+	for _, t := range elementTypes {
+		if t == elementType.ValueString() {
+			return t, nil
+		}
+	}
+
+	return "", []diag.Diagnostic{
+		diag.NewAttributeErrorDiagnostic(path.Root("element_type"), "invalid element type",
+			fmt.Sprintf("%s is not one of %s", elementType.ValueString(), strings.Join(elementTypes, ", "))),
+	}
+}
+
+// processFlatten returns how to flatten hashes, nil when flatten isn't set
+func processFlatten(flatten types.Bool, separator types.String, arrayIndex types.String) (*flattening, []diag.Diagnostic) {
+	if !flatten.ValueBool() {
+		return nil, nil
+	}
+
+	f := &flattening{separator: "."}
+	if !separator.IsNull() {
+		if separator.ValueString() == "" {
+			return nil, []diag.Diagnostic{
+				diag.NewAttributeErrorDiagnostic(path.Root("flatten_separator"), "invalid separator", "the separator must not be empty"),
+			}
+		}
+		f.separator = separator.ValueString()
+	}
+
+	switch arrayIndex.ValueString() {
+	case "", "key":
+	case "brackets":
+		f.brackets = true
+	default:
+		return nil, []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(path.Root("flatten_array_index"), "invalid array index",
+				fmt.Sprintf("%s is not one of key, brackets", arrayIndex.ValueString())),
+		}
+	}
+
+	return f, nil
+}
+
+// processDefault returns the default value, typedDefault unless null, and the
+// path of its attribute, failing when both are set
+func processDefault(defaultValue attr.Value, typedDefault types.Dynamic) (attr.Value, path.Path, []diag.Diagnostic) {
+	if typedDefault.IsNull() {
+		return defaultValue, path.Root("default"), nil
+	}
+
+	if !defaultValue.IsNull() {
+		return nil, path.Root("typed_default"), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(path.Root("typed_default"), "conflicting attributes", "default and typed_default can't both be set"),
+		}
+	}
+
+	return typedDefault, path.Root("typed_default"), nil
+}
+
+// processDefaultArray returns the default value at p as a list of strings and
+// as an array of elementType
+func processDefaultArray(value attr.Value, p path.Path, elementType string) (types.List, types.Dynamic, []diag.Diagnostic) {
+	v, ok := dgoValue(value).(dgo.Array)
+	if !ok {
+		return types.ListNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default", "the default value is not a list"),
+		}
+	}
+
+	list, err := listValue(v)
+	if err != nil {
+		return types.ListNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default", fmt.Sprintf("the default value is not a list: %s", err)),
+		}
+	}
+
+	typed, err := arrayValue(v, elementType)
+	if err != nil {
+		return types.ListNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default",
+				fmt.Sprintf("the default value is not a list of %s: %s", elementType, err)),
+		}
+	}
+
+	return list, typed, nil
+}
+
+// processDefaultHash returns the default value at p as a map of strings and as
+// a hash of elementType, flattened unless flatten is nil
+func processDefaultHash(value attr.Value, p path.Path, elementType string, flatten *flattening) (types.Map, types.Dynamic, []diag.Diagnostic) {
+	v, ok := dgoValue(value).(dgo.Map)
+	if !ok {
+		return types.MapNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default", "the default value is not a map"),
+		}
+	}
+
+	v, err := flatten.hash(v)
+	if err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default",
+				fmt.Sprintf("the default value can't be flattened: %s", err)),
+		}
+	}
+
+	hash, err := mapValue(v)
+	if err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default", fmt.Sprintf("the default value is not a map: %s", err)),
+		}
+	}
+
+	typed, err := hashValue(v, elementType)
+	if err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(p, "invalid default",
+				fmt.Sprintf("the default value is not a map of %s: %s", elementType, err)),
+		}
+	}
+
+	return hash, typed, nil
+}
+
+func processSources(sources []helper.Source) (types.List, []diag.Diagnostic) {
+	var diags []diag.Diagnostic
+
+	elements := make([]attr.Value, 0, len(sources))
+	for _, source := range sources {
+		element, d := types.ObjectValue(sourceAttributeTypes, map[string]attr.Value{
+			"level": types.StringValue(source.Level),
+			"path":  types.StringValue(source.Path),
+		})
+		diags = append(diags, d...)
+		elements = append(elements, element)
+	}
+
+	list, d := types.ListValue(types.ObjectType{AttrTypes: sourceAttributeTypes}, elements)
+
+	return list, append(diags, d...)
+}
+
+// sensitiveError refuses the value of key, which is sensitive and would end up
+// in plain text in plan output and state
+func sensitiveError(key string) diag.Diagnostic {
+	return diag.NewAttributeErrorDiagnostic(path.Root("key"), "sensitive value",
+		fmt.Sprintf("the value of key '%s' is sensitive and this data source would store it in plain text, use the hiera5_sensitive data source instead", key))
+}
+
+// processLookupError reports lookup errors that must not be masked by a default
+// value, which are all of them but the key not being found. Failed lookups are
+// reported on the attribute holding the looked up keys.
+func processLookupError(err error, keys path.Path) []diag.Diagnostic {
+	var (
+		scopeErr  *helper.ScopeError
+		schemaErr *helper.SchemaError
+	)
+
+	if errors.As(err, &scopeErr) {
+		return []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(path.Root("scope"), "undefined scope variable", scopeErr.Error()),
+		}
+	}
+
+	if errors.As(err, &schemaErr) {
+		if len(schemaErr.Violations) == 0 {
+			return []diag.Diagnostic{
+				diag.NewAttributeErrorDiagnostic(path.Root("schema"), "schema validation failed", schemaErr.Error()),
+			}
+		}
+
+		diags := make([]diag.Diagnostic, 0, len(schemaErr.Violations))
+		for _, v := range schemaErr.Violations {
+			diags = append(diags, diag.NewAttributeErrorDiagnostic(path.Root("key"), "schema validation failed",
+				fmt.Sprintf("value of key '%s' found in %s does not validate against schema %s at %s", schemaErr.Key, sourcePaths(schemaErr.Sources), schemaErr.Schema, v)))
+		}
+
+		return diags
+	}
+
+	if err != nil && !errors.Is(err, errNotFound) {
+		return []diag.Diagnostic{
+			diag.NewAttributeErrorDiagnostic(keys, "lookup failed", err.Error()),
+		}
+	}
+
+	return nil
+}
+
+func sourcePaths(sources []helper.Source) string {
+	paths := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if source.Path == "" {
+			paths = append(paths, fmt.Sprintf("'%s'", source.Level))
+		} else {
+			paths = append(paths, fmt.Sprintf("'%s'", source.Path))
+		}
+	}
+
+	return strings.Join(paths, ", ")
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
 var _ datasource.DataSource = &Hiera5StringDataSource{}
@@ -16,11 +18,14 @@ type Hiera5StringDataSource struct {
 }
 
 type Hiera5StringDataSourceModel struct {
-	ID      types.String `tfsdk:"id"`
-	Key     types.String `tfsdk:"key"`
-	Value   types.String `tfsdk:"value"`
-	Default types.String `tfsdk:"default"`
-	Scope   types.Map    `tfsdk:"scope"`
+	ID         types.String `tfsdk:"id"`
+	Key        types.String `tfsdk:"key"`
+	Value      types.String `tfsdk:"value"`
+	Default    types.String `tfsdk:"default"`
+	Scope      types.Map    `tfsdk:"scope"`
+	Schema     types.String `tfsdk:"schema"`
+	Sources    types.List   `tfsdk:"sources"`
+	SourceHash types.String `tfsdk:"source_hash"`
 }
 
 func NewStringDataSource() datasource.DataSource {
@@ -52,7 +57,10 @@ func (hb *Hiera5StringDataSource) Schema(ctx context.Context, req datasource.Sch
 				Optional:    true,
 				Description: defaultDescription,
 			},
-			"scope": scopeOverrideAttribute,
+			"scope":       scopeOverrideAttribute,
+			"schema":      schemaAttribute,
+			"sources":     sourcesAttribute,
+			"source_hash": sourceHashAttribute,
 		},
 	}
 }
@@ -70,7 +78,17 @@ func (hb *Hiera5StringDataSource) Read(ctx context.Context, req datasource.ReadR
 		return
 	}
 
-	v, err := hb.client.value(ctx, data.Key.ValueString(), WithScopeOverride(scopeOverride))
+	var (
+		sources   []helper.Source
+		sensitive bool
+	)
+
+	v, err := hb.client.value(ctx, data.Key.ValueString(), WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSources(&sources), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	if err != nil && data.Default.IsNull() {
 		resp.Diagnostics.AddAttributeError(path.Root("key"),
 			"key not found",
@@ -78,11 +96,26 @@ func (hb *Hiera5StringDataSource) Read(ctx context.Context, req datasource.ReadR
 		return
 	}
 
-	data.ID = data.Key
+	if sensitive {
+		resp.Diagnostics.Append(sensitiveError(data.Key.ValueString()))
+		return
+	}
+
+	data.ID = types.StringValue(hb.client.id(data.Key.ValueString(), WithScopeOverride(scopeOverride)))
+	data.Sources, diag = processSources(sources)
+	resp.Diagnostics.Append(diag...)
+
+	sourceHash, hashErr := hb.client.sourceHash(sources)
+	if hashErr != nil {
+		resp.Diagnostics.AddError("unable to hash sources", hashErr.Error())
+		return
+	}
+	data.SourceHash = types.StringValue(sourceHash)
+
 	if err != nil {
 		data.Value = data.Default
 	} else {
-		data.Value = types.StringValue(v)
+		data.Value = v
 	}
 
 	// Save data into Terraform state
@@ -3,11 +3,12 @@ package hiera5
 import (
 	"context"
 
-	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
 var _ datasource.DataSource = &Hiera5ArrayDataSource{}
@@ -17,11 +18,17 @@ type Hiera5ArrayDataSource struct {
 }
 
 type Hiera5ArrayDataSourceModel struct {
-	ID      types.String `tfsdk:"id"`
-	Key     types.String `tfsdk:"key"`
-	Value   types.List   `tfsdk:"value"`
-	Default types.List   `tfsdk:"default"`
-	Scope   types.Map    `tfsdk:"scope"`
+	ID           types.String  `tfsdk:"id"`
+	Key          types.String  `tfsdk:"key"`
+	Value        types.List    `tfsdk:"value"`
+	Default      types.List    `tfsdk:"default"`
+	TypedValue   types.Dynamic `tfsdk:"typed_value"`
+	TypedDefault types.Dynamic `tfsdk:"typed_default"`
+	ElementType  types.String  `tfsdk:"element_type"`
+	Scope        types.Map     `tfsdk:"scope"`
+	Schema       types.String  `tfsdk:"schema"`
+	Sources      types.List    `tfsdk:"sources"`
+	SourceHash   types.String  `tfsdk:"source_hash"`
 }
 
 func NewArrayDataSource() datasource.DataSource {
@@ -48,14 +55,26 @@ func (d *Hiera5ArrayDataSource) Schema(ctx context.Context, req datasource.Schem
 			"value": schema.ListAttribute{
 				ElementType: types.StringType,
 				Computed:    true,
-				Description: valueDescription,
+				Description: valueDescription + stringElementsDescription,
 			},
 			"default": schema.ListAttribute{
 				ElementType: types.StringType,
 				Optional:    true,
 				Description: defaultDescription,
 			},
-			"scope": scopeOverrideAttribute,
+			"typed_value": schema.DynamicAttribute{
+				Computed:    true,
+				Description: typedValueDescription,
+			},
+			"typed_default": schema.DynamicAttribute{
+				Optional:    true,
+				Description: typedDefaultDescription,
+			},
+			"element_type": elementTypeAttribute,
+			"scope":        scopeOverrideAttribute,
+			"schema":       schemaAttribute,
+			"sources":      sourcesAttribute,
+			"source_hash":  sourceHashAttribute,
 		},
 	}
 }
@@ -68,13 +87,31 @@ func (d *Hiera5ArrayDataSource) Read(ctx context.Context, req datasource.ReadReq
 
 	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
 
+	resp.Diagnostics.Append(diag...)
+
+	elementType, diag := processElementType(data.ElementType)
+
+	resp.Diagnostics.Append(diag...)
+
+	defaultValue, defaultPath, diag := processDefault(data.Default, data.TypedDefault)
+
 	resp.Diagnostics.Append(diag...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	rawList, err := d.client.array(ctx, data.Key.String(), WithScopeOverride(scopeOverride))
-	if err != nil && data.Default.IsNull() {
+	var (
+		sources   []helper.Source
+		sensitive bool
+	)
+
+	v, typed, err := d.client.array(ctx, data.Key.ValueString(), elementType, WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSources(&sources), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err != nil && defaultValue.IsNull() {
 		resp.Diagnostics.AddAttributeError(path.Root("key"),
 			"key not in data",
 			"When key is unavailable and a default value is not set an error is raised")
@@ -84,22 +121,30 @@ func (d *Hiera5ArrayDataSource) Read(ctx context.Context, req datasource.ReadReq
 		return
 	}
 
-	data.ID = data.Key
-	if err != nil {
-		data.Value = data.Default
-	} else {
-		listValue := []attr.Value{}
-		for _, v := range rawList {
-			listValue = append(listValue, types.StringValue(v.(string)))
-		}
+	if sensitive {
+		resp.Diagnostics.Append(sensitiveError(data.Key.ValueString()))
+		return
+	}
+
+	data.ID = types.StringValue(d.client.id(data.Key.ValueString(), WithScopeOverride(scopeOverride)))
+	data.Sources, diag = processSources(sources)
+	resp.Diagnostics.Append(diag...)
+
+	sourceHash, hashErr := d.client.sourceHash(sources)
+	if hashErr != nil {
+		resp.Diagnostics.AddError("unable to hash sources", hashErr.Error())
+		return
+	}
+	data.SourceHash = types.StringValue(sourceHash)
 
-		result, diag := types.ListValue(types.StringType, listValue)
+	if err != nil {
+		data.Value, data.TypedValue, diag = processDefaultArray(defaultValue, defaultPath, elementType)
 		resp.Diagnostics.Append(diag...)
 		if resp.Diagnostics.HasError() {
 			return
 		}
-
-		data.Value = result
+	} else {
+		data.Value, data.TypedValue = v, typed
 	}
 
 	// Save data into Terraform state
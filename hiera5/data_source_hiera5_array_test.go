@@ -113,3 +113,130 @@ func TestAccDataSourceHiera5Array_ScopeOverride(t *testing.T) {
 		},
 	})
 }
+
+func TestAccDataSourceHiera5Array_ElementType_Dynamic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "users"
+						element_type = "dynamic"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.#", "2"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.0.name", "alice"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.0.uid", "1001"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.1.groups.0", "deploy"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Array_ElementType_Number(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "ports"
+						element_type = "number"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.#", "2"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.1", "443"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "value.1", "443"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Array_ElementType_Dynamic_Default(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "missing_key"
+						typed_default = [{ name = "root", uid = 0 }]
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.#", "1"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.0.name", "root"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "typed_value.0.uid", "0"),
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "value.0", `{"name":"root","uid":0}`),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Array_ElementType_Invalid(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "ports"
+						element_type = "object"
+					}`,
+				ExpectError: regexp.MustCompile("invalid element type"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Array_ElementType_Nested(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "users"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_array.sut", "value.#", "2"),
+					resource.TestMatchResourceAttr("data.hiera5_array.sut", "value.0", regexp.MustCompile(`"name":"alice"`)),
+				),
+			},
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "users"
+						element_type = "string"
+					}`,
+				ExpectError: regexp.MustCompile(`set element_type = "dynamic"`),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Array_TypedDefault_Conflict(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_array" "sut" {
+						key = "missing_key"
+						default = ["value1"]
+						typed_default = ["value1"]
+					}`,
+				ExpectError: regexp.MustCompile("default and typed_default can't both be set"),
+			},
+		},
+	})
+}
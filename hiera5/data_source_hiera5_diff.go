@@ -0,0 +1,213 @@
+package hiera5
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ datasource.DataSource = &Hiera5DiffDataSource{}
+
+var changeAttributeTypes = map[string]attr.Type{
+	"key":          types.StringType,
+	"type":         types.StringType,
+	"from":         types.StringType,
+	"to":           types.StringType,
+	"from_sources": types.ListType{ElemType: types.ObjectType{AttrTypes: sourceAttributeTypes}},
+	"to_sources":   types.ListType{ElemType: types.ObjectType{AttrTypes: sourceAttributeTypes}},
+}
+
+type Hiera5DiffDataSource struct {
+	client hiera5
+}
+
+type Hiera5DiffDataSourceModel struct {
+	ID        types.String `tfsdk:"id"`
+	Keys      []string     `tfsdk:"keys"`
+	Namespace types.String `tfsdk:"namespace"`
+	From      types.Map    `tfsdk:"from"`
+	To        types.Map    `tfsdk:"to"`
+	Changes   types.List   `tfsdk:"changes"`
+	Scope     types.Map    `tfsdk:"scope"`
+	Schema    types.String `tfsdk:"schema"`
+}
+
+func NewDiffDataSource() datasource.DataSource {
+	return &Hiera5DiffDataSource{}
+}
+
+func (hd *Hiera5DiffDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "hiera5_diff"
+}
+
+func (hd *Hiera5DiffDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	hd.client = req.ProviderData.(hiera5)
+}
+
+func (hd *Hiera5DiffDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Compares the values of keys between two scopes, e.g. before promoting one environment to another.",
+		Attributes: map[string]schema.Attribute{
+			"id": idAttribute,
+			"keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "The keys to compare.",
+				Optional:    true,
+			},
+			"namespace": schema.StringAttribute{
+				Description: "Key holding a hash whose entries are compared one by one. They are reported as `namespace.entry`.",
+				Optional:    true,
+			},
+			"from": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "The scope to compare from. It is layered on top of the provider scope, or the `scope` attribute when set.",
+				Required:    true,
+			},
+			"to": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "The scope to compare to. It is layered on top of the provider scope, or the `scope` attribute when set.",
+				Required:    true,
+			},
+			"changes": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The keys whose values differ between the two scopes, sorted by key.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"key": schema.StringAttribute{
+							Computed:    true,
+							Description: "The key, or `namespace.entry` for entries of `namespace`.",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "One of `added`, `removed` or `changed`.",
+						},
+						"from": schema.StringAttribute{
+							Computed:    true,
+							Description: "The JSON encoded value in the `from` scope. Null when `added`.",
+						},
+						"to": schema.StringAttribute{
+							Computed:    true,
+							Description: "The JSON encoded value in the `to` scope. Null when `removed`.",
+						},
+						"from_sources": changeSourcesAttribute("from"),
+						"to_sources":   changeSourcesAttribute("to"),
+					},
+				},
+			},
+			"scope":  scopeOverrideAttribute,
+			"schema": schemaAttribute,
+		},
+	}
+}
+
+func changeSourcesAttribute(side string) schema.ListNestedAttribute {
+	return schema.ListNestedAttribute{
+		Computed:     true,
+		Description:  "The hierarchy levels, and the data files within them, that produced the value in the `" + side + "` scope.",
+		NestedObject: sourcesAttribute.NestedObject,
+	}
+}
+
+func (hd *Hiera5DiffDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var (
+		data      Hiera5DiffDataSourceModel
+		sensitive bool
+	)
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
+	resp.Diagnostics.Append(diag...)
+
+	from, diag := processScopeOverrideAttribute(ctx, data.From)
+	resp.Diagnostics.Append(diag...)
+
+	to, diag := processScopeOverrideAttribute(ctx, data.To)
+	resp.Diagnostics.Append(diag...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if len(data.Keys) == 0 && data.Namespace.ValueString() == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("keys"),
+			"no keys",
+			"at least one of keys or namespace must be set")
+		return
+	}
+
+	diffs, err := hd.client.diff(ctx, data.Keys, data.Namespace.ValueString(), from, to, WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("keys"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if sensitive {
+		resp.Diagnostics.AddAttributeError(path.Root("keys"),
+			"sensitive value",
+			"some of the compared values are sensitive and this data source would store them in plain text, compare them with hiera5_sensitive data sources instead")
+		return
+	}
+
+	data.Changes, diag = processChanges(diffs)
+	resp.Diagnostics.Append(diag...)
+
+	// The id covers the compared keys and both scopes
+	baseScope := scopeOverride
+	if baseScope == nil {
+		baseScope = hd.client.Scope
+	}
+	key, _ := json.Marshal(map[string]interface{}{"keys": data.Keys, "namespace": data.Namespace.ValueString()})
+	diffScope := map[string]interface{}{"scope": baseScope, "from": from, "to": to}
+	data.ID = types.StringValue(hd.client.id(string(key), WithScopeOverride(diffScope)))
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func processChanges(diffs []difference) (types.List, []diag.Diagnostic) {
+	var diags []diag.Diagnostic
+
+	elements := make([]attr.Value, 0, len(diffs))
+	for _, d := range diffs {
+		fromSources, ds := processSources(d.FromSources)
+		diags = append(diags, ds...)
+
+		toSources, ds := processSources(d.ToSources)
+		diags = append(diags, ds...)
+
+		element, ds := types.ObjectValue(changeAttributeTypes, map[string]attr.Value{
+			"key":          types.StringValue(d.Key),
+			"type":         types.StringValue(d.Type),
+			"from":         jsonValueOrNull(d.From),
+			"to":           jsonValueOrNull(d.To),
+			"from_sources": fromSources,
+			"to_sources":   toSources,
+		})
+		diags = append(diags, ds...)
+		elements = append(elements, element)
+	}
+
+	list, ds := types.ListValue(types.ObjectType{AttrTypes: changeAttributeTypes}, elements)
+
+	return list, append(diags, ds...)
+}
+
+func jsonValueOrNull(v string) types.String {
+	if v == "" {
+		return types.StringNull()
+	}
+
+	return types.StringValue(v)
+}
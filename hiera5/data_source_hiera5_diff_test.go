@@ -0,0 +1,64 @@
+package hiera5
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceHiera5Diff_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_diff" "sut" {
+						keys      = ["aws_instance_size", "aws_cloudwatch_enable"]
+						namespace = "aws_tags"
+						from = {
+							"environment" = "live"
+						}
+						to = {
+							"environment" = "stage"
+						}
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.#", "2"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.key", "aws_cloudwatch_enable"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.type", "changed"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.from", "true"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.to", "false"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.from_sources.0.level", "Environment"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.0.to_sources.0.level", "Common"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.1.key", "aws_tags.tier"),
+					resource.TestCheckResourceAttr("data.hiera5_diff.sut", "changes.1.type", "removed"),
+					resource.TestCheckNoResourceAttr("data.hiera5_diff.sut", "changes.1.to"),
+					resource.TestCheckResourceAttrSet("data.hiera5_diff.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Diff_NoKeys(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_diff" "sut" {
+						from = {
+							"environment" = "live"
+						}
+						to = {
+							"environment" = "stage"
+						}
+					}`,
+				ExpectError: regexp.MustCompile("no keys"),
+			},
+		},
+	})
+}
@@ -3,11 +3,12 @@ package hiera5
 import (
 	"context"
 
-	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
 var _ datasource.DataSource = &Hiera5HashDataSource{}
@@ -17,11 +18,20 @@ type Hiera5HashDataSource struct {
 }
 
 type Hiera5HashDataSourceModel struct {
-	ID      types.String `tfsdk:"id"`
-	Key     types.String `tfsdk:"key"`
-	Value   types.Map    `tfsdk:"value"`
-	Default types.Map    `tfsdk:"default"`
-	Scope   types.Map    `tfsdk:"scope"`
+	ID           types.String  `tfsdk:"id"`
+	Key          types.String  `tfsdk:"key"`
+	Value        types.Map     `tfsdk:"value"`
+	Default      types.Map     `tfsdk:"default"`
+	TypedValue   types.Dynamic `tfsdk:"typed_value"`
+	TypedDefault types.Dynamic `tfsdk:"typed_default"`
+	ElementType  types.String  `tfsdk:"element_type"`
+	Flatten      types.Bool    `tfsdk:"flatten"`
+	Separator    types.String  `tfsdk:"flatten_separator"`
+	ArrayIndex   types.String  `tfsdk:"flatten_array_index"`
+	Scope        types.Map     `tfsdk:"scope"`
+	Schema       types.String  `tfsdk:"schema"`
+	Sources      types.List    `tfsdk:"sources"`
+	SourceHash   types.String  `tfsdk:"source_hash"`
 }
 
 func NewHashDataSource() datasource.DataSource {
@@ -46,16 +56,40 @@ func (hb *Hiera5HashDataSource) Schema(ctx context.Context, req datasource.Schem
 			"id":  idAttribute,
 			"key": keyAttribute,
 			"value": schema.MapAttribute{
-				Computed:    true,
 				ElementType: types.StringType,
-				Description: valueDescription,
+				Computed:    true,
+				Description: valueDescription + stringElementsDescription,
 			},
 			"default": schema.MapAttribute{
-				Optional:    true,
 				ElementType: types.StringType,
+				Optional:    true,
 				Description: defaultDescription,
 			},
-			"scope": scopeOverrideAttribute,
+			"typed_value": schema.DynamicAttribute{
+				Computed:    true,
+				Description: typedValueDescription,
+			},
+			"typed_default": schema.DynamicAttribute{
+				Optional:    true,
+				Description: typedDefaultDescription,
+			},
+			"element_type": elementTypeAttribute,
+			"flatten": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Flatten nested hashes and arrays into a single level map whose keys are the paths to the values, e.g. `a.b.0.c`. Empty nested hashes and arrays are left out. Default: false",
+			},
+			"flatten_separator": schema.StringAttribute{
+				Optional:    true,
+				Description: "The separator joining the elements of flattened keys. Default: .",
+			},
+			"flatten_array_index": schema.StringAttribute{
+				Optional:    true,
+				Description: "How array indexes appear in flattened keys, either `key`, as in `a.0`, or `brackets`, as in `a[0]`. Default: key",
+			},
+			"scope":       scopeOverrideAttribute,
+			"schema":      schemaAttribute,
+			"sources":     sourcesAttribute,
+			"source_hash": sourceHashAttribute,
 		},
 	}
 }
@@ -68,32 +102,65 @@ func (hb *Hiera5HashDataSource) Read(ctx context.Context, req datasource.ReadReq
 
 	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
 
+	resp.Diagnostics.Append(diag...)
+
+	elementType, diag := processElementType(data.ElementType)
+
+	resp.Diagnostics.Append(diag...)
+
+	flatten, diag := processFlatten(data.Flatten, data.Separator, data.ArrayIndex)
+
+	resp.Diagnostics.Append(diag...)
+
+	defaultValue, defaultPath, diag := processDefault(data.Default, data.TypedDefault)
+
 	resp.Diagnostics.Append(diag...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	v, err := hb.client.hash(ctx, data.Key.ValueString(), WithScopeOverride(scopeOverride))
-	if err != nil && data.Default.IsNull() {
+	var (
+		sources   []helper.Source
+		sensitive bool
+	)
+
+	v, typed, err := hb.client.hash(ctx, data.Key.ValueString(), elementType, flatten, WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSources(&sources), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err != nil && defaultValue.IsNull() {
 		resp.Diagnostics.AddAttributeError(path.Root("key"),
 			"key not found",
 			"key was not found in the data and no default value was set")
 		return
 	}
 
-	data.ID = data.Key
-	if err != nil {
-		data.Value = data.Default
-	} else {
-		value := map[string]attr.Value{}
-		for k, v := range v {
-			value[k] = types.StringValue(v.(string))
-		}
+	if sensitive {
+		resp.Diagnostics.Append(sensitiveError(data.Key.ValueString()))
+		return
+	}
 
-		actualValue, diag := types.MapValue(types.StringType, value)
-		resp.Diagnostics.Append(diag...)
+	data.ID = types.StringValue(hb.client.id(data.Key.ValueString(), WithScopeOverride(scopeOverride)))
+	data.Sources, diag = processSources(sources)
+	resp.Diagnostics.Append(diag...)
+
+	sourceHash, hashErr := hb.client.sourceHash(sources)
+	if hashErr != nil {
+		resp.Diagnostics.AddError("unable to hash sources", hashErr.Error())
+		return
+	}
+	data.SourceHash = types.StringValue(sourceHash)
 
-		data.Value = actualValue
+	if err != nil {
+		data.Value, data.TypedValue, diag = processDefaultHash(defaultValue, defaultPath, elementType, flatten)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		data.Value, data.TypedValue = v, typed
 	}
 
 	// Save data into Terraform state
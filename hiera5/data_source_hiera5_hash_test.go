@@ -22,6 +22,12 @@ func TestAccDataSourceHiera5Hash_Basic(t *testing.T) {
 					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.tier", "1"),
 					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.team", "A"),
 					resource.TestCheckResourceAttrSet("data.hiera5_hash.sut", "id"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.#", "3"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.0.level", "Service"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.0.path", "hieradata/service/api.yaml"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.1.level", "Environment"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.2.level", "Common"),
+					resource.TestMatchResourceAttr("data.hiera5_hash.sut", "source_hash", regexp.MustCompile("^[0-9a-f]{64}$")),
 				),
 			},
 		},
@@ -69,6 +75,8 @@ func TestAccDataSourceHiera5Hash_Default_NotFound(t *testing.T) {
 					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.%", "1"),
 					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.service", "unknown"),
 					resource.TestCheckResourceAttrSet("data.hiera5_hash.sut", "id"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "sources.#", "0"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "source_hash", ""),
 				),
 			},
 		},
@@ -114,3 +122,49 @@ func TestAccDataSourceHiera5Hash_ScopeOverride(t *testing.T) {
 		},
 	})
 }
+
+func TestAccDataSourceHiera5Hash_ElementType_Dynamic_Default(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_hash" "sut" {
+						key = "missing_key"
+						typed_default = {
+							database = { host = "localhost", ports = [5432] }
+						}
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "typed_value.database.host", "localhost"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "typed_value.database.ports.0", "5432"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.database", `{"host":"localhost","ports":[5432]}`),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Hash_Flatten(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_hash" "sut" {
+						key = "service"
+						flatten = true
+						flatten_separator = "/"
+						flatten_array_index = "brackets"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.%", "4"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.env/LOG_LEVEL", "info"),
+					resource.TestCheckResourceAttr("data.hiera5_hash.sut", "value.listeners[1]/port", "443"),
+				),
+			},
+		},
+	})
+}
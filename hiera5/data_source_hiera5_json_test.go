@@ -104,3 +104,44 @@ func TestAccDataSourceHiera5JSON_ScopeOverride(t *testing.T) {
 		},
 	})
 }
+
+func TestAccDataSourceHiera5Json_Schema(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_json" "sut" {
+						key = "aws_tags"
+						schema = "test-fixtures/schemas/aws_tags.json"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_json.sut", "value", `{"team":"A","tier":1}`),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Json_SchemaViolation(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_json" "sut" {
+						key = "aws_tags"
+						default = "{}"
+						schema = jsonencode({
+							properties = {
+								tier = { type = "string" }
+							}
+						})
+					}`,
+				ExpectError: regexp.MustCompile("schema validation failed"),
+			},
+		},
+	})
+}
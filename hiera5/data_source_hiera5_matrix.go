@@ -0,0 +1,190 @@
+package hiera5
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ datasource.DataSource = &Hiera5MatrixDataSource{}
+
+type Hiera5MatrixDataSource struct {
+	client hiera5
+}
+
+type Hiera5MatrixDataSourceModel struct {
+	ID     types.String `tfsdk:"id"`
+	Key    types.String `tfsdk:"key"`
+	Scopes types.Map    `tfsdk:"scopes"`
+	Matrix types.Map    `tfsdk:"matrix"`
+	Value  types.Map    `tfsdk:"value"`
+	JSON   types.Map    `tfsdk:"json"`
+	Scope  types.Map    `tfsdk:"scope"`
+	Schema types.String `tfsdk:"schema"`
+}
+
+func NewMatrixDataSource() datasource.DataSource {
+	return &Hiera5MatrixDataSource{}
+}
+
+func (hb *Hiera5MatrixDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "hiera5_matrix"
+}
+
+func (hb *Hiera5MatrixDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	hb.client = req.ProviderData.(hiera5)
+}
+
+func (hb *Hiera5MatrixDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Looks a key up in many scopes at once, reusing a single hiera session for all of them.",
+		Attributes: map[string]schema.Attribute{
+			"id":  idAttribute,
+			"key": keyAttribute,
+			"scopes": schema.MapAttribute{
+				ElementType: types.MapType{ElemType: types.StringType},
+				Description: "Map of labels to the scope to look the key up in. Each scope is layered on top of the provider scope, or the `scope` attribute when set.",
+				Optional:    true,
+			},
+			"matrix": schema.MapAttribute{
+				ElementType:         types.ListType{ElemType: types.StringType},
+				MarkdownDescription: "Map of scope variables to the list of values they take. The key is looked up in every combination of them, labeled by the `variable=value` pairs of the combination joined by `,` in variable name order, e.g. `environment=live,region=eu`.",
+				Optional:            true,
+			},
+			"value": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Map of labels to the result of the lookup in the corresponding scope. Arrays and hashes are JSON encoded. Scopes in which the key is not found are left out.",
+			},
+			"json": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Map of labels to the JSON encoded result of the lookup in the corresponding scope. Scopes in which the key is not found are left out.",
+			},
+			"scope":  scopeOverrideAttribute,
+			"schema": schemaAttribute,
+		},
+	}
+}
+
+func (hb *Hiera5MatrixDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var (
+		data      Hiera5MatrixDataSourceModel
+		scopes    map[string]map[string]string
+		matrix    map[string][]string
+		sensitive bool
+	)
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
+	resp.Diagnostics.Append(diag...)
+
+	if !data.Scopes.IsNull() {
+		resp.Diagnostics.Append(data.Scopes.ElementsAs(ctx, &scopes, false)...)
+	}
+
+	if !data.Matrix.IsNull() {
+		resp.Diagnostics.Append(data.Matrix.ElementsAs(ctx, &matrix, false)...)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if scopes == nil && matrix == nil {
+		resp.Diagnostics.AddAttributeError(path.Root("scopes"),
+			"no scopes",
+			"at least one of scopes or matrix must be set")
+		return
+	}
+
+	labeled := scopeMatrix(matrix)
+	for label, scope := range scopes {
+		if _, ok := labeled[label]; ok {
+			resp.Diagnostics.AddAttributeError(path.Root("scopes"),
+				"duplicate label",
+				fmt.Sprintf("label '%s' is also produced by matrix", label))
+			return
+		}
+
+		labeled[label] = map[string]interface{}{}
+		for k, v := range scope {
+			labeled[label][k] = v
+		}
+	}
+
+	value, jsonValue, err := hb.client.matrix(ctx, data.Key.ValueString(), labeled, WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if sensitive {
+		resp.Diagnostics.Append(sensitiveError(data.Key.ValueString()))
+		return
+	}
+
+	// The id covers the base scope and every scope layered on top of it
+	baseScope := scopeOverride
+	if baseScope == nil {
+		baseScope = hb.client.Scope
+	}
+	matrixScope := map[string]interface{}{"scope": baseScope, "scopes": labeled}
+	data.ID = types.StringValue(hb.client.id(data.Key.ValueString(), WithScopeOverride(matrixScope)))
+
+	data.Value, diag = types.MapValue(types.StringType, value)
+	resp.Diagnostics.Append(diag...)
+
+	data.JSON, diag = types.MapValue(types.StringType, jsonValue)
+	resp.Diagnostics.Append(diag...)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// scopeMatrix returns the cartesian product of the values of the variables in
+// matrix, labeled by their variable=value pairs in variable name order
+func scopeMatrix(matrix map[string][]string) map[string]map[string]interface{} {
+	scopes := map[string]map[string]interface{}{}
+	if len(matrix) == 0 {
+		return scopes
+	}
+
+	names := make([]string, 0, len(matrix))
+	for name := range matrix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var product func(i int, labels []string, scope map[string]interface{})
+	product = func(i int, labels []string, scope map[string]interface{}) {
+		if i == len(names) {
+			combination := make(map[string]interface{}, len(scope))
+			for k, v := range scope {
+				combination[k] = v
+			}
+			scopes[strings.Join(labels, ",")] = combination
+			return
+		}
+
+		for _, value := range matrix[names[i]] {
+			scope[names[i]] = value
+			product(i+1, append(labels, names[i]+"="+value), scope)
+		}
+	}
+	product(0, nil, map[string]interface{}{})
+
+	return scopes
+}
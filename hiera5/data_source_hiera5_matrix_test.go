@@ -0,0 +1,103 @@
+package hiera5
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceHiera5Matrix_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_matrix" "sut" {
+						key = "aws_instance_size"
+						scopes = {
+							"api" = {
+								"service" = "api"
+							}
+							"worker" = {
+								"service" = "worker"
+							}
+						}
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.%", "2"),
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.api", "t2.large"),
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.worker", "t2.micro"),
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "json.api", `"t2.large"`),
+					resource.TestCheckResourceAttrSet("data.hiera5_matrix.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Matrix_Matrix(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_matrix" "sut" {
+						key = "aws_tags"
+						matrix = {
+							"service"     = ["api", "worker"]
+							"environment" = ["live", "stage"]
+						}
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.%", "4"),
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.environment=live,service=api", `{"team":"A","tier":1}`),
+					resource.TestCheckResourceAttr("data.hiera5_matrix.sut", "value.environment=stage,service=worker", `{}`),
+					resource.TestCheckResourceAttrSet("data.hiera5_matrix.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Matrix_DuplicateLabel(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_matrix" "sut" {
+						key = "aws_tags"
+						scopes = {
+							"service=api" = {
+								"service" = "api"
+							}
+						}
+						matrix = {
+							"service" = ["api"]
+						}
+					}`,
+				ExpectError: regexp.MustCompile("duplicate label"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Matrix_NoScopes(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_matrix" "sut" {
+						key = "aws_tags"
+					}`,
+				ExpectError: regexp.MustCompile("no scopes"),
+			},
+		},
+	})
+}
@@ -0,0 +1,163 @@
+package hiera5
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+var _ datasource.DataSource = &Hiera5NumberDataSource{}
+
+type Hiera5NumberDataSource struct {
+	client hiera5
+}
+
+type Hiera5NumberDataSourceModel struct {
+	ID          types.String `tfsdk:"id"`
+	Key         types.String `tfsdk:"key"`
+	Value       types.Number `tfsdk:"value"`
+	Default     types.Number `tfsdk:"default"`
+	IntegerOnly types.Bool   `tfsdk:"integer_only"`
+	Min         types.Number `tfsdk:"min"`
+	Max         types.Number `tfsdk:"max"`
+	Scope       types.Map    `tfsdk:"scope"`
+	Schema      types.String `tfsdk:"schema"`
+	Sources     types.List   `tfsdk:"sources"`
+	SourceHash  types.String `tfsdk:"source_hash"`
+}
+
+func NewNumberDataSource() datasource.DataSource {
+	return &Hiera5NumberDataSource{}
+}
+
+func (hb *Hiera5NumberDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "hiera5_number"
+}
+
+func (hb *Hiera5NumberDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	hb.client = req.ProviderData.(hiera5)
+}
+
+func (hb *Hiera5NumberDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id":  idAttribute,
+			"key": keyAttribute,
+			"default": schema.NumberAttribute{
+				Optional:    true,
+				Description: defaultDescription,
+			},
+			"value": schema.NumberAttribute{
+				Computed:    true,
+				Description: valueDescription + " Integers are exact whatever their size.",
+			},
+			"integer_only": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Fail when the value isn't an integer. Default: false",
+			},
+			"min": schema.NumberAttribute{
+				Optional:    true,
+				Description: "Fail when the value is less than min.",
+			},
+			"max": schema.NumberAttribute{
+				Optional:    true,
+				Description: "Fail when the value is greater than max.",
+			},
+			"scope":       scopeOverrideAttribute,
+			"schema":      schemaAttribute,
+			"sources":     sourcesAttribute,
+			"source_hash": sourceHashAttribute,
+		},
+	}
+}
+
+func (hb *Hiera5NumberDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data Hiera5NumberDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
+
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var (
+		sources   []helper.Source
+		sensitive bool
+	)
+
+	v, err := hb.client.number(ctx, data.Key.ValueString(), WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSources(&sources), WithSensitive(&sensitive))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err != nil && data.Default.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("key"),
+			"key not found",
+			"the value was not found and the default value was not set")
+		return
+	}
+
+	if sensitive {
+		resp.Diagnostics.Append(sensitiveError(data.Key.ValueString()))
+		return
+	}
+
+	data.ID = types.StringValue(hb.client.id(data.Key.ValueString(), WithScopeOverride(scopeOverride)))
+	data.Sources, diag = processSources(sources)
+	resp.Diagnostics.Append(diag...)
+
+	sourceHash, hashErr := hb.client.sourceHash(sources)
+	if hashErr != nil {
+		resp.Diagnostics.AddError("unable to hash sources", hashErr.Error())
+		return
+	}
+	data.SourceHash = types.StringValue(sourceHash)
+
+	if err != nil {
+		data.Value = data.Default
+	} else {
+		data.Value = v
+	}
+
+	if err := checkNumber(data.Value.ValueBigFloat(), data.IntegerOnly.ValueBool(), data.Min.ValueBigFloat(), data.Max.ValueBigFloat()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("key"), "invalid number", fmt.Sprintf("the value of key '%s' %s", data.Key.ValueString(), err))
+		return
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// checkNumber returns an error when v isn't an integer while integerOnly is
+// set, or when v is out of the bounds min and max, either of which may be nil
+func checkNumber(v *big.Float, integerOnly bool, min *big.Float, max *big.Float) error {
+	if integerOnly && !v.IsInt() {
+		return fmt.Errorf("%s is not an integer", v.Text('f', -1))
+	}
+
+	if min != nil && v.Cmp(min) < 0 {
+		return fmt.Errorf("%s is less than %s", v.Text('f', -1), min.Text('f', -1))
+	}
+
+	if max != nil && v.Cmp(max) > 0 {
+		return fmt.Errorf("%s is greater than %s", v.Text('f', -1), max.Text('f', -1))
+	}
+
+	return nil
+}
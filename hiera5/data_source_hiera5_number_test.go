@@ -0,0 +1,115 @@
+package hiera5
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceHiera5Number_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "max_connections"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_number.sut", "value", "500"),
+					resource.TestCheckResourceAttrSet("data.hiera5_number.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Number_Default_NotFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "max_workers"
+						default = 4
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_number.sut", "value", "4"),
+					resource.TestCheckResourceAttrSet("data.hiera5_number.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Number_NotFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "max_workers"
+					}`,
+				ExpectError: regexp.MustCompile(".*"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Number_IntegerOnly(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "cpu_ratio"
+						integer_only = true
+					}`,
+				ExpectError: regexp.MustCompile("is not an integer"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Number_Range(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "max_connections"
+						min = 1
+						max = 200
+					}`,
+				ExpectError: regexp.MustCompile("is greater than 200"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Number_Default_NotANumber(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_number" "sut" {
+						key = "aws_instance_size"
+						default = 4
+					}`,
+				ExpectError: regexp.MustCompile("does not return a valid number"),
+			},
+		},
+	})
+}
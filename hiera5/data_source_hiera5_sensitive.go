@@ -0,0 +1,128 @@
+package hiera5
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+var _ datasource.DataSource = &Hiera5SensitiveDataSource{}
+
+type Hiera5SensitiveDataSource struct {
+	client hiera5
+}
+
+type Hiera5SensitiveDataSourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	Key        types.String `tfsdk:"key"`
+	Value      types.String `tfsdk:"value"`
+	JSON       types.String `tfsdk:"json"`
+	Default    types.String `tfsdk:"default"`
+	Scope      types.Map    `tfsdk:"scope"`
+	Schema     types.String `tfsdk:"schema"`
+	Sources    types.List   `tfsdk:"sources"`
+	SourceHash types.String `tfsdk:"source_hash"`
+}
+
+func NewSensitiveDataSource() datasource.DataSource {
+	return &Hiera5SensitiveDataSource{}
+}
+
+func (hb *Hiera5SensitiveDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "hiera5_sensitive"
+}
+
+func (hb *Hiera5SensitiveDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	hb.client = req.ProviderData.(hiera5)
+}
+
+func (hb *Hiera5SensitiveDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Looks up a value that is kept out of plan output, whether or not hiera considers it sensitive.",
+		Attributes: map[string]schema.Attribute{
+			"id":  idAttribute,
+			"key": keyAttribute,
+			"value": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: valueDescription + " Arrays and hashes are JSON encoded.",
+			},
+			"json": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The JSON encoded result of the lookup in the hiera data, or the JSON encoded default value if the key is not found.",
+			},
+			"default": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: defaultDescription,
+			},
+			"scope":       scopeOverrideAttribute,
+			"schema":      schemaAttribute,
+			"sources":     sourcesAttribute,
+			"source_hash": sourceHashAttribute,
+		},
+	}
+}
+
+func (hb *Hiera5SensitiveDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data Hiera5SensitiveDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	scopeOverride, diag := processScopeOverrideAttribute(ctx, data.Scope)
+
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var sources []helper.Source
+
+	v, out, err := hb.client.plain(ctx, data.Key.ValueString(), WithScopeOverride(scopeOverride), WithSchema(data.Schema.ValueString()), WithSources(&sources))
+	resp.Diagnostics.Append(processLookupError(err, path.Root("key"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err != nil && data.Default.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("key"),
+			"key not found",
+			"the value was not found and the default value was not set")
+		return
+	}
+
+	data.ID = types.StringValue(hb.client.id(data.Key.ValueString(), WithScopeOverride(scopeOverride)))
+	data.Sources, diag = processSources(sources)
+	resp.Diagnostics.Append(diag...)
+
+	sourceHash, hashErr := hb.client.sourceHash(sources)
+	if hashErr != nil {
+		resp.Diagnostics.AddError("unable to hash sources", hashErr.Error())
+		return
+	}
+	data.SourceHash = types.StringValue(sourceHash)
+
+	if err != nil {
+		b, _ := json.Marshal(data.Default.ValueString())
+		data.Value = data.Default
+		data.JSON = types.StringValue(string(b))
+	} else {
+		data.Value = v
+		data.JSON = types.StringValue(out)
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
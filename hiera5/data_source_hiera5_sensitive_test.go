@@ -0,0 +1,140 @@
+package hiera5
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceHiera5Sensitive_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_sensitive" "sut" {
+						key = "db_password"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "value", "s3cr3t"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "json", `"s3cr3t"`),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "sources.0.level", "Common"),
+					resource.TestCheckResourceAttrSet("data.hiera5_sensitive.sut", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Sensitive_Default(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_sensitive" "sut" {
+						key     = "doesnt_exists"
+						default = "changeme"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "value", "changeme"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "json", `"changeme"`),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Sensitive_NotFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_sensitive" "sut" {
+						key = "doesnt_exists"
+					}`,
+				ExpectError: regexp.MustCompile("key not found"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Sensitive_Eyaml(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_sensitive" "sut" {
+						key = "db_url"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "value", "postgres://app:pa55@db/app"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "sources.0.level", "Secrets"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "sources.0.path", "hieradata/secrets.eyaml"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Sensitive_Sops(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5_sensitive" "sut" {
+						key = "sops_settings"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "json", `{"enabled":true,"ratio":0.5}`),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "sources.0.level", "Sops"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "sources.0.path", "hieradata/secrets.sops.yaml"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5Sensitive_ConvertTo(t *testing.T) {
+	config := `
+provider "hiera5" {
+	config = "test-fixtures/hiera-types.yaml"
+	scope = {
+		"environment" = "live"
+	}
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: config + `
+					data "hiera5_sensitive" "sut" {
+						key = "api_key"
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "value", "k3y"),
+					resource.TestCheckResourceAttr("data.hiera5_sensitive.sut", "json", `"k3y"`),
+				),
+			},
+			{
+				Config: config + `
+					data "hiera5" "sut" {
+						key = "api_key"
+					}`,
+				ExpectError: regexp.MustCompile("use the hiera5_sensitive data source"),
+			},
+		},
+	})
+}
@@ -1,10 +1,12 @@
 package hiera5
 
 import (
+	"fmt"
 	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestAccDataSourceHiera5_Basic(t *testing.T) {
@@ -104,3 +106,132 @@ func TestAccDataSourceHiera5_ScopeOverride(t *testing.T) {
 		},
 	})
 }
+
+func TestAccDataSourceHiera5_StrictScope(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "hiera5" {
+						config = "test-fixtures/hiera.yaml"
+						scope = {
+							"service" = "api"
+							"facts" = "{'timezone'=>'CET'}"
+						}
+						strict_scope = true
+					}
+
+					data "hiera5" "sut" {
+						key = "aws_instance_size"
+						default = "t2.nano"
+					}`,
+				ExpectError: regexp.MustCompile("undefined scope variable"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5_ScopedID(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5" "live" {
+						key = "aws_instance_size"
+					}
+
+					data "hiera5" "worker" {
+						key = "aws_instance_size"
+						scope = {
+							"service" = "worker"
+							"environment" = "live"
+						}
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.hiera5.live", "id"),
+					resource.TestCheckResourceAttrSet("data.hiera5.worker", "id"),
+					resource.TestCheckResourceAttrPair("data.hiera5.live", "key", "data.hiera5.worker", "key"),
+					func(s *terraform.State) error {
+						live := s.RootModule().Resources["data.hiera5.live"].Primary.ID
+						worker := s.RootModule().Resources["data.hiera5.worker"].Primary.ID
+						if live == worker {
+							return fmt.Errorf("ids of different scopes are equal: %s", live)
+						}
+
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5_Sensitive(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					data "hiera5" "sut" {
+						key = "db_password"
+					}`,
+				ExpectError: regexp.MustCompile("use the hiera5_sensitive data source"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5_Default_BackendError(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "hiera5" {
+						config = "test-fixtures/hiera-broken.yaml"
+					}
+
+					data "hiera5" "sut" {
+						key = "replicas"
+						default = "3"
+					}`,
+				ExpectError: regexp.MustCompile("could not unmarshal"),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceHiera5_SourceHash_Directory(t *testing.T) {
+	config, _ := newStateDirectoryConfig(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "hiera5" {
+						config = %q
+						scope = {
+							"environment" = "live"
+						}
+					}
+
+					data "hiera5" "sut" {
+						key = "cluster"
+					}`, config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.hiera5.sut", "value", "live-1"),
+					resource.TestCheckResourceAttr("data.hiera5.sut", "sources.0.level", "State"),
+					resource.TestCheckResourceAttrSet("data.hiera5.sut", "source_hash"),
+				),
+			},
+		},
+	})
+}
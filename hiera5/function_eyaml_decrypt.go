@@ -0,0 +1,73 @@
+package hiera5
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+var _ function.Function = &EyamlDecryptFunction{}
+
+type EyamlDecryptFunction struct{}
+
+func NewEyamlDecryptFunction() function.Function {
+	return &EyamlDecryptFunction{}
+}
+
+func (f *EyamlDecryptFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "eyaml_decrypt"
+}
+
+func (f *EyamlDecryptFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Decrypt hiera-eyaml values",
+		MarkdownDescription: "Replaces the `ENC[PKCS7,...]` blocks in a value by their plaintext using a hiera-eyaml private key. Like any function result, the plaintext is only sensitive when `value` is: wrap it in `sensitive()` to keep it out of plans and outputs.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "value",
+				Description: "The value holding one or more eyaml blocks.",
+			},
+			function.StringParameter{
+				Name:        "private_key",
+				Description: "The PEM encoded private key, or the path to it.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *EyamlDecryptFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var value, privateKey string
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &value, &privateKey))
+	if resp.Error != nil {
+		return
+	}
+
+	key, err := readPEMArgument(privateKey)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	plaintext, err := helper.EyamlDecrypt(value, key)
+	if err != nil {
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, plaintext))
+}
+
+// readPEMArgument returns the PEM encoded key given either inline or as a path to it
+func readPEMArgument(key string) ([]byte, error) {
+	if strings.Contains(key, "-----BEGIN") {
+		return []byte(key), nil
+	}
+
+	return os.ReadFile(key)
+}
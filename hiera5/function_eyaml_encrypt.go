@@ -0,0 +1,62 @@
+package hiera5
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+var _ function.Function = &EyamlEncryptFunction{}
+
+type EyamlEncryptFunction struct{}
+
+func NewEyamlEncryptFunction() function.Function {
+	return &EyamlEncryptFunction{}
+}
+
+func (f *EyamlEncryptFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "eyaml_encrypt"
+}
+
+func (f *EyamlEncryptFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Encrypt a value for hiera-eyaml",
+		MarkdownDescription: "Encrypts plaintext with a hiera-eyaml public key and returns the `ENC[PKCS7,...]` block, ready to be written to an eyaml data file. Every call draws a new random key, so the block differs from one plan to the next: use the `hiera5_eyaml_encrypted` resource to keep a block until the plaintext changes.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "plaintext",
+				Description: "The value to encrypt.",
+			},
+			function.StringParameter{
+				Name:        "public_key",
+				Description: "The PEM encoded public key certificate, or the path to it.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *EyamlEncryptFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var plaintext, publicKey string
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &plaintext, &publicKey))
+	if resp.Error != nil {
+		return
+	}
+
+	cert, err := readPEMArgument(publicKey)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	block, err := helper.EyamlEncrypt(plaintext, cert)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, block))
+}
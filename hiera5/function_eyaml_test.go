@@ -0,0 +1,117 @@
+package hiera5
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+func TestEyamlFunctions(t *testing.T) {
+	encrypted := runTestFunction(t, NewEyamlEncryptFunction(), "s3cr3t", "test-fixtures/keys/public_key.pkcs7.pem")
+	if !regexp.MustCompile(`^ENC\[PKCS7,[A-Za-z0-9+/=]+\]$`).MatchString(encrypted) {
+		t.Fatalf("eyaml_encrypt returned %s", encrypted)
+	}
+
+	decrypted := runTestFunction(t, NewEyamlDecryptFunction(), encrypted, "test-fixtures/keys/private_key.pkcs7.pem")
+	if decrypted != "s3cr3t" {
+		t.Errorf("eyaml_decrypt returned %s; want %s", decrypted, "s3cr3t")
+	}
+
+	// Blocks of the resource decrypt the same way
+	cert, err := readPEMArgument("test-fixtures/keys/public_key.pkcs7.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err = helper.EyamlEncrypt("s3cr3t", cert)
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+
+	decrypted = runTestFunction(t, NewEyamlDecryptFunction(), encrypted, "test-fixtures/keys/private_key.pkcs7.pem")
+	if decrypted != "s3cr3t" {
+		t.Errorf("eyaml_decrypt returned %s; want %s", decrypted, "s3cr3t")
+	}
+}
+
+func runTestFunction(t *testing.T, f function.Function, args ...string) string {
+	values := make([]attr.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, types.StringValue(arg))
+	}
+
+	resp := &function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+	f.Run(context.TODO(), function.RunRequest{Arguments: function.NewArgumentsData(values)}, resp)
+	if resp.Error != nil {
+		t.Fatalf("Error running function: %s", resp.Error)
+	}
+
+	v, ok := resp.Result.Value().(types.String)
+	if !ok {
+		t.Fatalf("Error function returned %s", resp.Result.Value())
+	}
+
+	return v.ValueString()
+}
+
+func TestAccFunctionEyaml_RoundTrip(t *testing.T) {
+	config := providerConfig + `
+		resource "hiera5_eyaml_encrypted" "sut" {
+			plaintext  = "s3cr3t"
+			public_key = "test-fixtures/keys/public_key.pkcs7.pem"
+		}
+
+		output "sut" {
+			value = provider::hiera5::eyaml_decrypt(
+				hiera5_eyaml_encrypted.sut.value,
+				"test-fixtures/keys/private_key.pkcs7.pem",
+			)
+		}`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("hiera5_eyaml_encrypted.sut", "value", regexp.MustCompile(`^ENC\[PKCS7,[A-Za-z0-9+/=]+\]$`)),
+					resource.TestCheckOutput("sut", "s3cr3t"),
+				),
+			},
+			{
+				// The block in state is kept rather than encrypted again
+				Config:   config,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccFunctionEyaml_Encrypt(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		IsUnitTest:               true,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig + `
+					output "sut" {
+						value = provider::hiera5::eyaml_decrypt(
+							provider::hiera5::eyaml_encrypt("s3cr3t", "test-fixtures/keys/public_key.pkcs7.pem"),
+							"test-fixtures/keys/private_key.pkcs7.pem",
+						)
+					}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckOutput("sut", "s3cr3t"),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,235 @@
+package helper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleTree returns a directory holding the files of the tar, tar.gz or zip
+// archive at path, and the SHA-256 digest of the archive. The archive is read
+// in memory once, verified and served by BundleFS, then written once per
+// content and per s since hiera reads its config and data files from the file
+// system. When checksum is not empty, the digest, hex encoded and optionally
+// prefixed by sha256:, must match it.
+func (s *Sessions) BundleTree(path string, checksum string) (string, string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256(b)
+	digest := hex.EncodeToString(sum[:])
+
+	if checksum != "" && !strings.EqualFold(strings.TrimPrefix(checksum, "sha256:"), digest) {
+		return "", "", fmt.Errorf("the checksum of %s is sha256:%s, expected %s", path, digest, checksum)
+	}
+
+	fsys, err := BundleFS(b)
+	if err != nil {
+		return "", "", fmt.Errorf("could not read %s: %s", path, err.Error())
+	}
+
+	dir, err := s.tree("bundle", digest, func(dir string) error {
+		if err := os.CopyFS(dir, fsys); err != nil {
+			return fmt.Errorf("could not extract %s: %s", path, err.Error())
+		}
+		return nil
+	})
+
+	return dir, digest, err
+}
+
+// BundleFS returns the files of the tar, tar.gz or zip archive b, telling its
+// format by its content. Only directories and regular files are kept, and
+// every name must be local to the archive.
+func BundleFS(b []byte) (fs.FS, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		return tarFS(gr)
+	}
+
+	return tarFS(bytes.NewReader(b))
+}
+
+// tarFS reads the directories and regular files of the tar stream r in memory.
+// Other entries, like symbolic links, are skipped.
+func tarFS(r io.Reader) (fs.FS, error) {
+	files := memFS{".": {info: memInfo{name: ".", mode: fs.ModeDir | 0o755}}}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("%s is outside of the archive", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			files.add(name, nil, memInfo{name: path.Base(name), mode: fs.ModeDir | 0o755, time: hdr.ModTime})
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files.add(name, data, memInfo{name: path.Base(name), size: int64(len(data)), mode: hdr.FileInfo().Mode().Perm(), time: hdr.ModTime})
+		}
+	}
+}
+
+// memFS is a read-only fs.FS of the files and directories it maps by name
+type memFS map[string]*memEntry
+
+// memEntry is a file or a directory of a memFS
+type memEntry struct {
+	info     memInfo
+	data     []byte
+	children map[string]bool
+}
+
+// add adds the file or directory name, and its parent directories when missing
+func (f memFS) add(name string, data []byte, info memInfo) {
+	if e, ok := f[name]; ok && e.info.mode.IsDir() && info.mode.IsDir() {
+		e.info = info
+		return
+	}
+	f[name] = &memEntry{info: info, data: data}
+
+	for name != "." {
+		dir := path.Dir(name)
+		parent, ok := f[dir]
+		if !ok {
+			parent = &memEntry{info: memInfo{name: path.Base(dir), mode: fs.ModeDir | 0o755, time: info.time}}
+			f[dir] = parent
+		}
+		if parent.children == nil {
+			parent.children = make(map[string]bool)
+		}
+		if parent.children[name] {
+			return
+		}
+		parent.children[name] = true
+		name = dir
+	}
+}
+
+func (f memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	e, ok := f[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if !e.info.mode.IsDir() {
+		return &memFile{Reader: bytes.NewReader(e.data), info: e.info}, nil
+	}
+
+	names := make([]string, 0, len(e.children))
+	for child := range e.children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, len(names))
+	for i, child := range names {
+		entries[i] = fs.FileInfoToDirEntry(f[child].info)
+	}
+
+	return &memDir{name: name, info: e.info, entries: entries}, nil
+}
+
+// memFile is an open file of a memFS
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// memDir is an open directory of a memFS
+type memDir struct {
+	name    string
+	info    memInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) Close() error {
+	return nil
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+// memInfo describes a file or a directory of a memFS
+type memInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	time time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.time }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
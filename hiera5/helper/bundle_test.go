@@ -0,0 +1,197 @@
+package helper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var bundleFiles = map[string]string{
+	"hieradata-1.42/hiera.yaml":         "version: 5\nhierarchy:\n  - name: Common\n    glob: \"*.yaml\"\n",
+	"hieradata-1.42/data/common.yaml":   "replicas: 4\n",
+	"hieradata-1.42/data/defaults.yaml": "timeout: 30\n",
+}
+
+func tarBundle(t *testing.T, files map[string]string, compress bool) []byte {
+	var (
+		b  bytes.Buffer
+		w  io.Writer = &b
+		gw *gzip.Writer
+	)
+
+	if compress {
+		gw = gzip.NewWriter(&b)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if compress {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func zipBundle(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func writeBundle(t *testing.T, name string, b []byte) (string, string) {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(b)
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestBundleTree(t *testing.T) {
+	s := &Sessions{}
+	defer s.Close()
+
+	for name, b := range map[string][]byte{
+		"hieradata-1.42.tgz": tarBundle(t, bundleFiles, true),
+		"hieradata-1.42.tar": tarBundle(t, bundleFiles, false),
+		"hieradata-1.42.zip": zipBundle(t, bundleFiles),
+	} {
+		path, digest := writeBundle(t, name, b)
+
+		for _, checksum := range []string{"", digest, "sha256:" + strings.ToUpper(digest)} {
+			tree, sum, err := s.BundleTree(path, checksum)
+			if err != nil {
+				t.Fatalf("Error extracting %s with checksum %q: %s", name, checksum, err)
+			}
+			if sum != digest {
+				t.Errorf("Error digest of %s is %s; want %s", name, sum, digest)
+			}
+
+			config := filepath.Join(tree, "hieradata-1.42", "hiera.yaml")
+			for key, want := range map[string]string{"replicas": `4`, "timeout": `30`} {
+				r, err := Lookup(context.TODO(), config, "first", key, "", map[string]interface{}{})
+				out, _ := r.JSON()
+				if err != nil {
+					t.Errorf("Error lookup %s in %s: %s", key, name, err)
+				}
+
+				if v := strings.TrimSpace(string(out)); v != want {
+					t.Errorf("%s in %s is %s; want %s", key, name, v, want)
+				}
+			}
+		}
+	}
+}
+
+func TestBundleFS(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"tgz": tarBundle(t, bundleFiles, true),
+		"tar": tarBundle(t, bundleFiles, false),
+		"zip": zipBundle(t, bundleFiles),
+	} {
+		fsys, err := BundleFS(b)
+		if err != nil {
+			t.Fatalf("Error reading the %s bundle: %s", name, err)
+		}
+
+		if err := fstest.TestFS(fsys, "hieradata-1.42/hiera.yaml", "hieradata-1.42/data/common.yaml", "hieradata-1.42/data/defaults.yaml"); err != nil {
+			t.Errorf("Error %s bundle: %s", name, err)
+		}
+	}
+}
+
+func TestBundleTreeErrors(t *testing.T) {
+	s := &Sessions{}
+	defer s.Close()
+
+	path, _ := writeBundle(t, "hieradata.tgz", tarBundle(t, bundleFiles, true))
+	if _, _, err := s.BundleTree(path, "sha256:0000"); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Error extracting with a wrong checksum is %v; want a checksum mismatch", err)
+	}
+
+	path, _ = writeBundle(t, "evil.tgz", tarBundle(t, map[string]string{"../evil.yaml": "a: 1\n"}, true))
+	if _, _, err := s.BundleTree(path, ""); err == nil {
+		t.Errorf("Error extracting files outside of the bundle should fail")
+	}
+
+	if _, _, err := s.BundleTree(filepath.Join(t.TempDir(), "doesnt_exists.tgz"), ""); err == nil {
+		t.Errorf("Error extracting a missing bundle should fail")
+	}
+}
+
+func TestBundleTreePrivate(t *testing.T) {
+	path, _ := writeBundle(t, "hieradata-1.42.tgz", tarBundle(t, bundleFiles, true))
+
+	s := &Sessions{}
+	defer s.Close()
+
+	tree, _, err := s.BundleTree(path, "")
+	if err != nil {
+		t.Fatalf("Error extracting %s: %s", path, err)
+	}
+
+	// Files altered after the extraction of a run are not seen by the next runs
+	common := filepath.Join(tree, "hieradata-1.42", "data", "common.yaml")
+	if err := os.WriteFile(common, []byte("replicas: 666\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Sessions{}
+	defer other.Close()
+
+	again, _, err := other.BundleTree(path, "")
+	if err != nil {
+		t.Fatalf("Error extracting %s again: %s", path, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(again, "hieradata-1.42", "data", "common.yaml"))
+	if err != nil || strings.Contains(string(b), "666") {
+		t.Errorf("Error %s reads the files of another run: %s, %v", again, b, err)
+	}
+
+	s.Close()
+	if _, err := os.Stat(tree); !os.IsNotExist(err) {
+		t.Errorf("Error %s should be removed on close: %v", tree, err)
+	}
+}
@@ -0,0 +1,157 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/config"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+	"gopkg.in/yaml.v3"
+)
+
+// hieraConfigsPrefix prefixes the keys of the configs hiera keeps in the shared
+// cache of a session, once loaded
+const hieraConfigsPrefix = `HieraConfig:`
+
+// useConfig loads the hiera config at path ahead of hiera, adapted to the
+// options of s, for the session c. hiera only reads the default plugin
+// directory from the environment, which all sessions of the process share,
+// and fails to call the data_dig functions it is given.
+func (s *Sessions) useConfig(c api.Session, path string) {
+	if s.PluginDir == "" && len(s.DataDigs) == 0 {
+		return
+	}
+
+	cfg := sessionConfig{Config: config.New(path), dataDigs: make(map[string]bool, len(s.DataDigs))}
+
+	if s.PluginDir != "" && !setsPluginDir(path) {
+		cfg.pluginDir = s.PluginDir
+		if !filepath.IsAbs(cfg.pluginDir) {
+			cfg.pluginDir = filepath.Join(cfg.Root(), cfg.pluginDir)
+		}
+	}
+
+	for name := range s.DataDigs {
+		cfg.dataDigs[name] = true
+	}
+
+	c.SharedCache().Store(hieraConfigsPrefix+path, cfg)
+}
+
+// setsPluginDir tells whether the hiera config at path sets plugindir in its defaults
+func setsPluginDir(path string) bool {
+	var cfg struct {
+		Defaults struct {
+			PluginDir string `yaml:"plugindir"`
+		} `yaml:"defaults"`
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	// hiera reports invalid configs when loading them
+	_ = yaml.Unmarshal(b, &cfg)
+
+	return cfg.Defaults.PluginDir != ""
+}
+
+// sessionConfig is a hiera config whose defaults use pluginDir as plugin
+// directory, unless empty, and whose hierarchy levels calling one of dataDigs
+// call it as a lookup_key function instead
+type sessionConfig struct {
+	api.Config
+	pluginDir string
+	dataDigs  map[string]bool
+}
+
+func (c sessionConfig) Defaults() api.Entry {
+	return sessionEntry{Entry: c.Config.Defaults(), pluginDir: c.pluginDir, dataDigs: c.dataDigs}
+}
+
+func (c sessionConfig) Hierarchy() []api.Entry {
+	return c.entries(c.Config.Hierarchy())
+}
+
+func (c sessionConfig) DefaultHierarchy() []api.Entry {
+	return c.entries(c.Config.DefaultHierarchy())
+}
+
+func (c sessionConfig) entries(entries []api.Entry) []api.Entry {
+	wrapped := make([]api.Entry, len(entries))
+	for i, e := range entries {
+		wrapped[i] = sessionEntry{Entry: e, dataDigs: c.dataDigs}
+	}
+	return wrapped
+}
+
+// sessionEntry is a hierarchy entry of a sessionConfig, as well as the entries
+// resolved or copied from it
+type sessionEntry struct {
+	api.Entry
+	pluginDir string
+	dataDigs  map[string]bool
+}
+
+func (e sessionEntry) PluginDir() string {
+	if e.pluginDir != "" {
+		return e.pluginDir
+	}
+	return e.Entry.PluginDir()
+}
+
+func (e sessionEntry) Function() api.Function {
+	f := e.Entry.Function()
+	if f != nil && f.Kind() == api.KindDataDig && e.dataDigs[f.Name()] {
+		return lookupKeyFunction{f}
+	}
+	return f
+}
+
+func (e sessionEntry) Copy(c api.Config) api.Entry {
+	if sc, ok := c.(sessionConfig); ok {
+		c = sc.Config
+	}
+	return sessionEntry{Entry: e.Entry.Copy(c), pluginDir: e.pluginDir, dataDigs: e.dataDigs}
+}
+
+func (e sessionEntry) Resolve(ic api.Invocation, defaults api.Entry) api.Entry {
+	return sessionEntry{Entry: e.Entry.Resolve(ic, defaults), pluginDir: e.pluginDir, dataDigs: e.dataDigs}
+}
+
+// lookupKeyFunction is a data_dig function called as a lookup_key function
+type lookupKeyFunction struct {
+	api.Function
+}
+
+func (f lookupKeyFunction) Kind() api.FunctionKind {
+	return api.KindLookupKey
+}
+
+func (f lookupKeyFunction) Resolve(ic api.Invocation) (api.Function, bool) {
+	resolved, changed := f.Function.Resolve(ic)
+	return lookupKeyFunction{resolved}, changed
+}
+
+// digLookupKey returns the lookup_key function calling fn with the root of the
+// looked up keys, hiera digging into the value it returns
+func digLookupKey(fn sdk.DataDig) sdk.LookupKey {
+	return func(pc sdk.ProviderContext, key string) dgo.Value {
+		return fn(pc, vf.Values(key))
+	}
+}
+
+// configRelative returns path resolved against the directory of the hiera
+// config of the lookup sc serves when relative, as the paths of backend
+// options are
+func configRelative(sc api.ServerContext, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(sc.Invocation().SessionOptions().Get(api.HieraConfig).String()), path)
+}
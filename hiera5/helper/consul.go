@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+// consulLookupKey is a lookup_key function that reads key from the Consul KV
+// store, below the path given by the prefix option, which usually interpolates
+// scope variables. The address and token options default to CONSUL_HTTP_ADDR
+// and CONSUL_HTTP_TOKEN, the datacenter option to the one of the agent. YAML
+// and JSON values are decoded.
+func consulLookupKey(pc sdk.ProviderContext, key string) dgo.Value {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return nil
+	}
+
+	address := os.Getenv("CONSUL_HTTP_ADDR")
+	if av := sc.Option(`address`); av != nil {
+		address = av.String()
+	}
+
+	if address == "" {
+		panic(api.MissingRequiredOption(`address`))
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	pv := sc.Option(`prefix`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`prefix`))
+	}
+
+	query := url.Values{"raw": {""}}
+	if dv := sc.Option(`datacenter`); dv != nil {
+		query.Set("dc", dv.String())
+	}
+
+	path := strings.Trim(pv.String(), "/") + "/" + key
+	kvURL := strings.TrimRight(address, "/") + "/v1/kv/" + (&url.URL{Path: path}).EscapedPath() + "?" + query.Encode()
+
+	cacheKey := `consul::` + kvURL
+	value, ok := sc.CachedValue(cacheKey)
+	if !ok {
+		token := os.Getenv("CONSUL_HTTP_TOKEN")
+		if tv := sc.Option(`token`); tv != nil {
+			token = tv.String()
+		}
+
+		value = readConsulValue(kvURL, token)
+		sc.Cache(cacheKey, value)
+	}
+
+	if value == vf.Nil {
+		return nil
+	}
+
+	return value
+}
+
+// readConsulValue returns the decoded value of the KV entry at kvURL, or
+// vf.Nil when there is no such entry
+func readConsulValue(kvURL string, token string) dgo.Value {
+	req, err := http.NewRequest(http.MethodGet, kvURL, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		panic(fmt.Errorf("could not read consul key %s: %s", redactURL(kvURL), err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return vf.Nil
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(fmt.Errorf("could not read consul key %s: %s", redactURL(kvURL), err.Error()))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		panic(fmt.Errorf("could not read consul key %s: %s: %s", redactURL(kvURL), resp.Status, strings.TrimSpace(string(b))))
+	}
+
+	return decodeYAMLValue(b)
+}
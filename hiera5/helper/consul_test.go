@@ -0,0 +1,122 @@
+package helper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newConsulServer(t *testing.T, token string) {
+	kv := map[string]string{
+		"/v1/kv/hiera/api/live/workers":     `8`,
+		"/v1/kv/hiera/api/live/limits":      `{"cpu": "500m", "memory": "1Gi"}`,
+		"/v1/kv/hiera/api/live/upstreams":   "- alpha\n- beta\n",
+		"/v1/kv/hiera/common/workers":       `2`,
+		"/v1/kv/hiera/common/log_format":    `json`,
+		"/v1/kv/hiera/common/banner":        `: not yaml [`,
+		"/v1/kv/hiera/common/profile::role": `web`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("ACL not found"))
+			return
+		}
+
+		if _, ok := r.URL.Query()["raw"]; !ok || r.URL.Query().Get("dc") != "dc2" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		value, ok := kv[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(value))
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("CONSUL_HTTP_ADDR", strings.TrimPrefix(srv.URL, "http://"))
+	t.Setenv("CONSUL_HTTP_TOKEN", "s3cr3t")
+}
+
+func TestLookupConsul(t *testing.T) {
+	newConsulServer(t, "s3cr3t")
+
+	for _, tc := range []struct {
+		key   string
+		want  string
+		level string
+	}{
+		{"workers", `8`, "Consul"},
+		{"limits", `{"cpu":"500m","memory":"1Gi"}`, "Consul"},
+		{"upstreams", `["alpha","beta"]`, "Consul"},
+		{"log_format", `"json"`, "Consul Common"},
+		{"banner", `": not yaml ["`, "Consul Common"},
+		{"profile::role", `"web"`, "Consul Common"},
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera-consul.yaml",
+			"first",
+			tc.key,
+			"",
+			map[string]interface{}{"service": "api", "environment": "live"})
+		out, _ := r.JSON()
+		sources, sensitive := r.Sources, r.Sensitive
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", tc.key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != tc.want {
+			t.Errorf("%s is %s; want %s", tc.key, v, tc.want)
+		}
+
+		if sensitive {
+			t.Errorf("%s is sensitive", tc.key)
+		}
+
+		if len(sources) != 1 || sources[0].Level != tc.level {
+			t.Errorf("%s sources are %v; want level %s", tc.key, sources, tc.level)
+		}
+	}
+}
+
+func TestLookupConsulNonExistant(t *testing.T) {
+	newConsulServer(t, "s3cr3t")
+
+	r, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera-consul.yaml",
+		"first",
+		"doesnt_exists",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live"})
+	out, _ := r.JSON()
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if len(out) != 0 {
+		t.Errorf("Error non existent key should return an empty value: %s", out)
+	}
+}
+
+func TestLookupConsulForbidden(t *testing.T) {
+	newConsulServer(t, "other")
+
+	_, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera-consul.yaml",
+		"first",
+		"workers",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live"})
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf("Error lookup with a denied token is %v; want 403 Forbidden", err)
+	}
+}
@@ -0,0 +1,205 @@
+package helper
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// tomlData is a data_hash function that reads a TOML document from the file
+// given by the path option
+func tomlData(pc sdk.ProviderContext) dgo.Map {
+	return readDataFile(pc, func(_ string, b []byte) (dgo.Map, error) {
+		return decodeTOML(b)
+	})
+}
+
+// hclData is a data_hash function that reads the attributes of an HCL file in
+// the .tfvars syntax, or of its JSON variant when the path ends with .json,
+// from the file given by the path option
+func hclData(pc sdk.ProviderContext) dgo.Map {
+	return readDataFile(pc, func(path string, b []byte) (dgo.Map, error) {
+		return decodeHCL(path, b)
+	})
+}
+
+// dotenvData is a data_hash function that reads the variables of a .env file
+// from the file given by the path option. All values are strings.
+func dotenvData(pc sdk.ProviderContext) dgo.Map {
+	return readDataFile(pc, func(_ string, b []byte) (dgo.Map, error) {
+		return decodeDotenv(b)
+	})
+}
+
+// readDataFile decodes the file given by the path option like the data_hash
+// functions built into hiera do, a missing file is an empty hash
+func readDataFile(pc sdk.ProviderContext, decode func(string, []byte) (dgo.Map, error)) dgo.Map {
+	pv := pc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vf.Map()
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	data, err := decode(path, b)
+	if err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+
+	return data
+}
+
+func decodeTOML(b []byte) (dgo.Map, error) {
+	var v map[string]interface{}
+
+	if err := toml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return vf.Value(tomlValue(v)).(dgo.Map), nil
+}
+
+// tomlValue replaces the arrays of tables in v with plain arrays
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = tomlValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = tomlValue(e)
+		}
+	case []map[string]interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = tomlValue(e)
+		}
+		return a
+	}
+
+	return v
+}
+
+func decodeHCL(path string, b []byte) (dgo.Map, error) {
+	var (
+		file  *hcl.File
+		diags hcl.Diagnostics
+	)
+
+	parser := hclparse.NewParser()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		file, diags = parser.ParseJSON(b, path)
+	} else {
+		file, diags = parser.ParseHCL(b, path)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	data := vf.MapWithCapacity(len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		j, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+
+		data.Put(name, streamer.UnmarshalJSON(j, nil))
+	}
+
+	return data, nil
+}
+
+// decodeDotenv decodes the KEY=value lines of b. Lines may start with export,
+// values may be single quoted (literal) or double quoted (with \n, \t, \" and
+// \\ escapes) and unquoted values end at a # preceded by a space.
+func decodeDotenv(b []byte) (dgo.Map, error) {
+	data := vf.MutableMap()
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+
+		value, err := dotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err.Error())
+		}
+
+		data.Put(key, value)
+	}
+
+	return data, scanner.Err()
+}
+
+func dotenvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(value, quote)
+		if end == 0 {
+			return "", errors.New("unterminated quoted value")
+		}
+
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", errors.New("unexpected characters after quoted value")
+		}
+
+		if quote == '\'' {
+			return value[1:end], nil
+		}
+
+		return strconv.Unquote(`"` + value[1:end] + `"`)
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value, nil
+}
@@ -0,0 +1,76 @@
+package helper
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLookupDataFiles(t *testing.T) {
+	for _, tc := range []struct {
+		key   string
+		want  string
+		level string
+	}{
+		{"title", `"live"`, "TOML"},
+		{"replicas", `3`, "TOML"},
+		{"released", `"2024-05-01T10:00:00Z"`, "TOML"},
+		{"database", `{"host":"db.live.internal","ports":[5432,5433]}`, "TOML"},
+		{"listeners", `[{"name":"http","port":80},{"name":"https","port":443}]`, "TOML"},
+		{"region", `"eu-west-1"`, "HCL"},
+		{"vpc", `{"cidr":"10.0.0.0/16","subnets":["10.0.1.0/24","10.0.2.0/24"],"tags":{"tier":"network"}}`, "HCL"},
+		{"enable_nat", `true`, "HCL"},
+		{"instance_type", `"t3.large"`, "HCL JSON"},
+		{"LOG_LEVEL", `"info"`, "Dotenv"},
+		{"REGION", `"us-east-1"`, "Dotenv"},
+		{"GREETING", `"hello\nworld"`, "Dotenv"},
+		{"PATTERN", `"%s #literal"`, "Dotenv"},
+		{"EMPTY", `""`, "Dotenv"},
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera-formats.yaml",
+			"first",
+			tc.key,
+			"",
+			map[string]interface{}{"environment": "live"})
+		out, _ := r.JSON()
+		sources := r.Sources
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", tc.key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != tc.want {
+			t.Errorf("%s is %s; want %s", tc.key, v, tc.want)
+		}
+
+		if len(sources) != 1 || sources[0].Level != tc.level {
+			t.Errorf("%s sources are %v; want level %s", tc.key, sources, tc.level)
+		}
+	}
+}
+
+func TestDecodeDotenvErrors(t *testing.T) {
+	for _, b := range []string{
+		"NO_VALUE\n",
+		"TWO WORDS=1\n",
+		"QUOTED=\"unterminated\n",
+		"QUOTED='value' trailing\n",
+	} {
+		if _, err := decodeDotenv([]byte(b)); err == nil {
+			t.Errorf("Error decoding %q should fail", b)
+		}
+	}
+}
+
+func TestDecodeHCLErrors(t *testing.T) {
+	for _, b := range []string{
+		"region = \n",
+		"region = var.region\n",
+		"vpc {\n  cidr = \"10.0.0.0/16\"\n}\n",
+	} {
+		if _, err := decodeHCL("live.tfvars", []byte(b)); err == nil {
+			t.Errorf("Error decoding %q should fail", b)
+		}
+	}
+}
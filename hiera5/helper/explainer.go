@@ -0,0 +1,145 @@
+package helper
+
+import (
+	"path/filepath"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+)
+
+// Source is a hierarchy level, and the data file within it, that contributed to a looked up value
+type Source struct {
+	Level string
+	Path  string
+}
+
+type frameKind int
+
+const (
+	otherFrame = frameKind(iota)
+	levelFrame
+	locationFrame
+	interpolationFrame
+)
+
+type frame struct {
+	kind  frameKind
+	value string
+}
+
+// sourceExplainer is an api.Explainer that records the hierarchy levels and
+// locations in which the looked up key is found while still building the
+// regular explanation
+type sourceExplainer struct {
+	api.Explainer
+	root    string
+	frames  []frame
+	sources []Source
+}
+
+func newSourceExplainer(config string) *sourceExplainer {
+	return &sourceExplainer{
+		Explainer: explain.NewExplainer(false, false),
+		root:      filepath.Dir(config),
+	}
+}
+
+func (ex *sourceExplainer) push(kind frameKind, value string) {
+	ex.frames = append(ex.frames, frame{kind: kind, value: value})
+}
+
+func (ex *sourceExplainer) PushDataProvider(pvd api.DataProvider) {
+	ex.Explainer.PushDataProvider(pvd)
+	ex.push(levelFrame, pvd.Hierarchy().Name())
+}
+
+func (ex *sourceExplainer) PushLocation(loc api.Location) {
+	ex.Explainer.PushLocation(loc)
+	ex.push(locationFrame, loc.Resolved())
+}
+
+func (ex *sourceExplainer) PushInterpolation(expr string) {
+	ex.Explainer.PushInterpolation(expr)
+	ex.push(interpolationFrame, expr)
+}
+
+func (ex *sourceExplainer) PushInvalidKey(key interface{}) {
+	ex.Explainer.PushInvalidKey(key)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) PushLookup(key api.Key) {
+	ex.Explainer.PushLookup(key)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) PushMerge(mrg api.MergeStrategy) {
+	ex.Explainer.PushMerge(mrg)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) PushModule(moduleName string) {
+	ex.Explainer.PushModule(moduleName)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) PushSegment(seg interface{}) {
+	ex.Explainer.PushSegment(seg)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) PushSubLookup(key api.Key) {
+	ex.Explainer.PushSubLookup(key)
+	ex.push(otherFrame, "")
+}
+
+func (ex *sourceExplainer) Pop() {
+	ex.Explainer.Pop()
+	if len(ex.frames) > 0 {
+		ex.frames = ex.frames[:len(ex.frames)-1]
+	}
+}
+
+func (ex *sourceExplainer) AcceptFound(key interface{}, value dgo.Value) {
+	ex.Explainer.AcceptFound(key, value)
+
+	var source Source
+	for _, f := range ex.frames {
+		switch f.kind {
+		case interpolationFrame:
+			// Values found by interpolations within the data aren't sources of the looked up key
+			return
+		case levelFrame:
+			source = Source{Level: f.value}
+		case locationFrame:
+			source.Path = ex.relative(f.value)
+		}
+	}
+
+	if source.Level == "" {
+		return
+	}
+
+	for _, s := range ex.sources {
+		if s == source {
+			return
+		}
+	}
+
+	ex.sources = append(ex.sources, source)
+}
+
+// Sources returns the sources recorded so far, in the order they were found
+func (ex *sourceExplainer) Sources() []Source {
+	return ex.sources
+}
+
+// relative returns path relative to the directory of the hiera config when it lives below it
+func (ex *sourceExplainer) relative(path string) string {
+	if rel, err := filepath.Rel(ex.root, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+
+	return path
+}
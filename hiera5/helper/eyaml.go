@@ -0,0 +1,445 @@
+package helper
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/provider"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+var (
+	eyamlBlock = regexp.MustCompile(`ENC\[PKCS7,([A-Za-z0-9+/=\s]+)\]`)
+
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerialNumber
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+// eyamlLookupKey is a lookup_key function that reads the YAML file given by the
+// path option, like yaml_data does, and decrypts the hiera-eyaml ENC[PKCS7,...]
+// blocks found in the value of key with the keys given by the pkcs7_private_key
+// and pkcs7_public_key options. Relative key paths are resolved against the
+// directory of the hiera config. Decrypted values are Sensitive.
+func eyamlLookupKey(pc sdk.ProviderContext, key string) dgo.Value {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return nil
+	}
+
+	pv := sc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+
+	cacheKey := `eyaml::data::` + pv.String()
+	data, ok := sc.CachedValue(cacheKey)
+	if !ok {
+		data = provider.YamlData(pc)
+		sc.Cache(cacheKey, data)
+	}
+
+	value := data.(dgo.Map).Get(key)
+	if value == nil {
+		return nil
+	}
+
+	return decryptEyaml(sc, sc.Interpolate(value))
+}
+
+// decryptEyaml replaces the encrypted blocks in every string within value by their plaintext
+func decryptEyaml(sc api.ServerContext, value dgo.Value) dgo.Value {
+	switch v := value.(type) {
+	case dgo.String:
+		s := v.GoString()
+		if !eyamlBlock.MatchString(s) {
+			return v
+		}
+
+		key, cert := eyamlKeys(sc)
+		s, err := decryptEyamlString(s, key, cert)
+		if err != nil {
+			panic(err)
+		}
+
+		return vf.Sensitive(vf.String(s))
+	case dgo.Array:
+		return v.Map(func(e dgo.Value) interface{} {
+			return decryptEyaml(sc, e)
+		})
+	case dgo.Map:
+		return v.Map(func(e dgo.MapEntry) interface{} {
+			return decryptEyaml(sc, e.Value())
+		})
+	}
+
+	return value
+}
+
+// decryptEyamlString replaces the encrypted blocks in s by their plaintext
+func decryptEyamlString(s string, key *rsa.PrivateKey, cert *x509.Certificate) (string, error) {
+	var err error
+
+	s = eyamlBlock.ReplaceAllStringFunc(s, func(block string) string {
+		if err != nil {
+			return block
+		}
+
+		var ciphertext, plaintext []byte
+		if ciphertext, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(eyamlBlock.FindStringSubmatch(block)[1]), "")); err != nil {
+			err = fmt.Errorf("unable to decode eyaml block: %s", err)
+			return block
+		}
+
+		if plaintext, err = decryptPKCS7(ciphertext, key, cert); err != nil {
+			err = fmt.Errorf("unable to decrypt eyaml block: %s", err)
+			return block
+		}
+
+		return string(plaintext)
+	})
+
+	return s, err
+}
+
+// EyamlEncrypt returns plaintext encrypted for the holder of the private key of
+// the PEM encoded certificate as a hiera-eyaml ENC[PKCS7,...] block
+func EyamlEncrypt(plaintext string, certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", errors.New("public key is not PEM encoded")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse public key: %s", err)
+	}
+
+	der, err := encryptPKCS7([]byte(plaintext), cert)
+	if err != nil {
+		return "", err
+	}
+
+	return "ENC[PKCS7," + base64.StdEncoding.EncodeToString(der) + "]", nil
+}
+
+// EyamlDecrypt replaces the hiera-eyaml ENC[PKCS7,...] blocks in s by their
+// plaintext, using the PEM encoded private key
+func EyamlDecrypt(s string, keyPEM []byte) (string, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return "", errors.New("private key is not PEM encoded")
+	}
+
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse private key: %s", err)
+	}
+
+	if !eyamlBlock.MatchString(s) {
+		return "", errors.New("no eyaml block found")
+	}
+
+	return decryptEyamlString(s, key, nil)
+}
+
+// eyamlKeyPair holds the parsed keys of an eyaml hierarchy level
+type eyamlKeyPair struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// eyamlKeys reads the private key, and the certificate when given, from the
+// options of sc. They are parsed once per session and key files.
+func eyamlKeys(sc api.ServerContext) (*rsa.PrivateKey, *x509.Certificate) {
+	kv := sc.Option(`pkcs7_private_key`)
+	if kv == nil {
+		panic(api.MissingRequiredOption(`pkcs7_private_key`))
+	}
+
+	cv := sc.Option(`pkcs7_public_key`)
+
+	cacheKey := `eyaml::keys::` + kv.String()
+	if cv != nil {
+		cacheKey += `::` + cv.String()
+	}
+
+	if cached, ok := sc.CachedValue(cacheKey); ok {
+		keys := cached.(dgo.Native).GoValue().(*eyamlKeyPair)
+		return keys.key, keys.cert
+	}
+
+	keys := &eyamlKeyPair{}
+
+	var err error
+	if keys.key, err = parsePrivateKey(readPEM(sc, kv.String()).Bytes); err != nil {
+		panic(fmt.Errorf("unable to parse pkcs7_private_key %s: %s", kv, err))
+	}
+
+	if cv != nil {
+		if keys.cert, err = x509.ParseCertificate(readPEM(sc, cv.String()).Bytes); err != nil {
+			panic(fmt.Errorf("unable to parse pkcs7_public_key %s: %s", cv, err))
+		}
+	}
+
+	sc.Cache(cacheKey, vf.Value(keys))
+
+	return keys.key, keys.cert
+}
+
+func readPEM(sc api.ServerContext, path string) *pem.Block {
+	path = configRelative(sc, path)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("could not read %s: %s", path, err))
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		panic(fmt.Errorf("%s is not PEM encoded", path))
+	}
+
+	return block
+}
+
+func parsePrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsaKey, nil
+	}
+
+	return nil, errors.New("not an RSA key")
+}
+
+// decryptPKCS7 decrypts DER encoded PKCS#7 enveloped data with key. When cert is
+// given only the recipient it identifies is tried.
+func decryptPKCS7(der []byte, key *rsa.PrivateKey, cert *x509.Certificate) ([]byte, error) {
+	var (
+		ci contentInfo
+		ed envelopedData
+	)
+
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	}
+
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("content type %s is not enveloped data", ci.ContentType)
+	}
+
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, err
+	}
+
+	var contentKey []byte
+	for _, ri := range ed.RecipientInfos {
+		if cert != nil && (!bytes.Equal(ri.IssuerAndSerialNumber.Issuer.FullBytes, cert.RawIssuer) || ri.IssuerAndSerialNumber.SerialNumber.Cmp(cert.SerialNumber) != 0) {
+			continue
+		}
+
+		if k, err := rsa.DecryptPKCS1v15(nil, key, ri.EncryptedKey); err == nil {
+			contentKey = k
+			break
+		}
+	}
+
+	if contentKey == nil {
+		return nil, errors.New("no recipient matches the private key")
+	}
+
+	eci := ed.EncryptedContentInfo
+	ciphertext, err := octets(eci.EncryptedContent)
+	if err != nil {
+		return nil, err
+	}
+
+	var iv []byte
+	if _, err = asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("invalid initialization vector: %s", err)
+	}
+
+	var block cipher.Block
+	switch alg := eci.ContentEncryptionAlgorithm.Algorithm; {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(contentKey)
+	case alg.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(contentKey)
+	default:
+		return nil, fmt.Errorf("unsupported content encryption algorithm %s", alg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted content")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	return unpad(plaintext, block.BlockSize())
+}
+
+// encryptPKCS7 encrypts plaintext with AES-256-CBC for the recipient of cert
+// and returns the DER encoded PKCS#7 enveloped data, like hiera-eyaml does
+func encryptPKCS7(plaintext []byte, cert *x509.Certificate) ([]byte, error) {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+
+	contentKey := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(contentKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := pad(plaintext, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	ed, err := asn1.Marshal(envelopedData{
+		RecipientInfos: []recipientInfo{{
+			IssuerAndSerialNumber: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		}},
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ed},
+	})
+}
+
+// octets returns the content of the implicitly tagged encrypted content, which
+// BER encoders may split into a constructed sequence of octet strings
+func octets(v asn1.RawValue) ([]byte, error) {
+	if !v.IsCompound {
+		return v.Bytes, nil
+	}
+
+	var out []byte
+	for rest := v.Bytes; len(rest) > 0; {
+		var chunk []byte
+
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+	}
+
+	return out, nil
+}
+
+func pad(b []byte, blockSize int) []byte {
+	n := blockSize - len(b)%blockSize
+
+	return append(append([]byte{}, b...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(b []byte, blockSize int) ([]byte, error) {
+	n := int(b[len(b)-1])
+	if n == 0 || n > blockSize || n > len(b) {
+		return nil, errors.New("invalid padding")
+	}
+
+	for _, p := range b[len(b)-n:] {
+		if int(p) != n {
+			return nil, errors.New("invalid padding")
+		}
+	}
+
+	return b[:len(b)-n], nil
+}
@@ -0,0 +1,150 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEyamlCiphertext(t *testing.T) []byte {
+	b, err := os.ReadFile("../test-fixtures/hieradata/secrets.eyaml")
+	if err != nil {
+		t.Fatalf("Error reading fixture: %s", err)
+	}
+
+	m := eyamlBlock.FindStringSubmatch(string(b))
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(m[1]), ""))
+	if err != nil {
+		t.Fatalf("Error decoding fixture: %s", err)
+	}
+
+	return ciphertext
+}
+
+func testEyamlKey(t *testing.T) *rsa.PrivateKey {
+	b, err := os.ReadFile("../test-fixtures/keys/private_key.pkcs7.pem")
+	if err != nil {
+		t.Fatalf("Error reading key: %s", err)
+	}
+
+	block, _ := pem.Decode(b)
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Error parsing key: %s", err)
+	}
+
+	return key
+}
+
+func TestDecryptPKCS7(t *testing.T) {
+	plaintext, err := decryptPKCS7(testEyamlCiphertext(t), testEyamlKey(t), nil)
+	if err != nil || string(plaintext) != "k3y" {
+		t.Errorf("decryptPKCS7 returned %s, %v; want %s", plaintext, err, "k3y")
+	}
+}
+
+func TestDecryptPKCS7WrongKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	if _, err = decryptPKCS7(testEyamlCiphertext(t), key, nil); err == nil {
+		t.Errorf("decryptPKCS7 with the wrong key should return an error")
+	}
+}
+
+func TestDecryptPKCS7WrongCertificate(t *testing.T) {
+	cert := &x509.Certificate{RawIssuer: []byte{0x30, 0x00}}
+
+	if _, err := decryptPKCS7(testEyamlCiphertext(t), testEyamlKey(t), cert); err == nil {
+		t.Errorf("decryptPKCS7 for another recipient should return an error")
+	}
+}
+
+func TestEyamlRoundTrip(t *testing.T) {
+	cert, err := os.ReadFile("../test-fixtures/keys/public_key.pkcs7.pem")
+	if err != nil {
+		t.Fatalf("Error reading public key: %s", err)
+	}
+
+	key, err := os.ReadFile("../test-fixtures/keys/private_key.pkcs7.pem")
+	if err != nil {
+		t.Fatalf("Error reading private key: %s", err)
+	}
+
+	block, err := EyamlEncrypt("s3cr3t", cert)
+	if err != nil || !strings.HasPrefix(block, "ENC[PKCS7,") {
+		t.Fatalf("EyamlEncrypt returned %s, %v", block, err)
+	}
+
+	plaintext, err := EyamlDecrypt("password: "+block, key)
+	if err != nil || plaintext != "password: s3cr3t" {
+		t.Errorf("EyamlDecrypt returned %s, %v; want %s", plaintext, err, "password: s3cr3t")
+	}
+
+	if _, err = EyamlDecrypt("s3cr3t", key); err == nil {
+		t.Errorf("EyamlDecrypt without block should return an error")
+	}
+
+	if _, err = EyamlEncrypt("s3cr3t", key); err == nil {
+		t.Errorf("EyamlEncrypt with a private key should return an error")
+	}
+}
+
+func TestEyamlKeysCached(t *testing.T) {
+	dir := t.TempDir()
+
+	for src, dst := range map[string]string{
+		"../test-fixtures/hieradata/secrets.eyaml":    "secrets.eyaml",
+		"../test-fixtures/keys/private_key.pkcs7.pem": "private_key.pkcs7.pem",
+		"../test-fixtures/keys/public_key.pkcs7.pem":  "public_key.pkcs7.pem",
+	} {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, dst), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := filepath.Join(dir, "hiera.yaml")
+	if err := os.WriteFile(config, []byte(`version: 5
+hierarchy:
+  - name: Secrets
+    lookup_key: eyaml_lookup_key
+    datadir: .
+    path: secrets.eyaml
+    options:
+      pkcs7_private_key: private_key.pkcs7.pem
+      pkcs7_public_key: public_key.pkcs7.pem
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Sessions{}
+	defer s.Close()
+
+	if r, err := s.Lookup(context.TODO(), config, "first", "api_key", "", nil); err != nil || !r.Found {
+		t.Fatalf("Error lookup api_key: %v %s", r.Value, err)
+	}
+
+	// The keys parsed by the first lookup serve the session once the files are gone
+	for _, f := range []string{"private_key.pkcs7.pem", "public_key.pkcs7.pem"} {
+		if err := os.Remove(filepath.Join(dir, f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r, err := s.Lookup(context.TODO(), config, "first", "db_url", "", nil); err != nil || !r.Found || !strings.HasPrefix(r.Value.String(), "postgres://app:") {
+		t.Errorf("Error lookup db_url: %v %s", r.Value, err)
+	}
+}
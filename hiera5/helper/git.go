@@ -0,0 +1,211 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitTree returns a directory holding the files of ref, a commit, tag or branch
+// of the local git repository repo, as they are in that revision regardless of
+// the working directory, and the hash of that commit. The files are read from
+// the object store of the repository through GitFS, without the git binary,
+// and written once per commit and per s since hiera reads its config and data
+// files from the file system.
+func (s *Sessions) GitTree(repo string, ref string) (string, string, error) {
+	fsys, commit, err := GitFS(repo, ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir, err := s.tree("git", commit, func(dir string) error {
+		if err := os.CopyFS(dir, fsys); err != nil {
+			return fmt.Errorf("could not export %s from %s: %s", ref, repo, err.Error())
+		}
+		return nil
+	})
+
+	return dir, commit, err
+}
+
+// GitFS returns the files of ref, a commit, tag or branch of the local git
+// repository repo, and the hash of that commit. Every file of the revision is
+// there, including those with the export-ignore attribute, but symbolic links
+// and submodules are left out.
+func GitFS(repo string, ref string) (fs.FS, string, error) {
+	r, err := git.PlainOpenWithOptions(repo, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open the git repository %s: %s", repo, err.Error())
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown git revision %s in %s: %s", ref, repo, err.Error())
+	}
+
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, "", fmt.Errorf("git revision %s in %s is not a commit: %s", ref, repo, err.Error())
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return gitFS{tree: tree, time: commit.Committer.When}, hash.String(), nil
+}
+
+// gitFS is the fs.FS of a git tree, whose files all have the time of the commit
+type gitFS struct {
+	tree *object.Tree
+	time time.Time
+}
+
+func (f gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return &gitDir{fs: f, name: name, tree: f.tree}, nil
+	}
+
+	entry, err := f.tree.FindEntry(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	switch {
+	case entry.Mode == filemode.Dir:
+		tree, err := f.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &gitDir{fs: f, name: name, tree: tree}, nil
+	case entry.Mode.IsFile() && entry.Mode != filemode.Symlink:
+		file, err := f.tree.TreeEntryFile(entry)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		r, err := file.Reader()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &gitFile{ReadCloser: r, info: f.fileInfo(path.Base(name), file)}, nil
+	default:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+}
+
+func (f gitFS) fileInfo(name string, file *object.File) gitInfo {
+	mode := fs.FileMode(0o644)
+	if file.Mode == filemode.Executable {
+		mode = 0o755
+	}
+
+	return gitInfo{name: name, size: file.Size, mode: mode, time: f.time}
+}
+
+// entries returns the directories and files of tree, in tree order
+func (f gitFS) entries(tree *object.Tree) ([]fs.DirEntry, error) {
+	entries := make([]fs.DirEntry, 0, len(tree.Entries))
+	for i := range tree.Entries {
+		entry := &tree.Entries[i]
+
+		switch {
+		case entry.Mode == filemode.Dir:
+			entries = append(entries, fs.FileInfoToDirEntry(gitInfo{name: entry.Name, mode: fs.ModeDir | 0o755, time: f.time}))
+		case entry.Mode.IsFile() && entry.Mode != filemode.Symlink:
+			file, err := tree.TreeEntryFile(entry)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(f.fileInfo(entry.Name, file)))
+		}
+	}
+
+	return entries, nil
+}
+
+// gitFile is an open file of a gitFS
+type gitFile struct {
+	io.ReadCloser
+	info gitInfo
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// gitDir is an open directory of a gitFS
+type gitDir struct {
+	fs      gitFS
+	name    string
+	tree    *object.Tree
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) {
+	return gitInfo{name: path.Base(d.name), mode: fs.ModeDir | 0o755, time: d.fs.time}, nil
+}
+
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *gitDir) Close() error {
+	return nil
+}
+
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fs.entries(d.tree)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+// gitInfo describes a file or a directory of a gitFS
+type gitInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	time time.Time
+}
+
+func (i gitInfo) Name() string       { return i.name }
+func (i gitInfo) Size() int64        { return i.size }
+func (i gitInfo) Mode() fs.FileMode  { return i.mode }
+func (i gitInfo) ModTime() time.Time { return i.time }
+func (i gitInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i gitInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,118 @@
+package helper
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// newGitRepo returns a repository whose v1 tag holds replicas: 1 and whose
+// working directory holds uncommitted replicas: 3
+func newGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	write := func(name string, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(repo, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "--quiet")
+	write("hiera/hiera.yaml", "version: 5\nhierarchy:\n  - name: Common\n    path: common.yaml\n")
+	write("hiera/data/common.yaml", "replicas: 1\n")
+	write(".gitattributes", "hiera/data/ignored.yaml export-ignore\n")
+	write("hiera/data/ignored.yaml", "ignored: true\n")
+	run("add", ".")
+	run("commit", "--quiet", "-m", "v1")
+	run("tag", "v1")
+	write("hiera/data/common.yaml", "replicas: 2\n")
+	run("commit", "--quiet", "-am", "v2")
+	write("hiera/data/common.yaml", "replicas: 3\n")
+
+	return repo
+}
+
+func TestGitTree(t *testing.T) {
+	repo := newGitRepo(t)
+	s := &Sessions{}
+	defer s.Close()
+	trees := []string{}
+
+	for ref, want := range map[string]string{"v1": `1`, "HEAD": `2`} {
+		tree, commit, err := s.GitTree(repo, ref)
+		if err != nil {
+			t.Fatalf("Error exporting %s: %s", ref, err)
+		}
+
+		r, err := Lookup(context.TODO(), filepath.Join(tree, "hiera", "hiera.yaml"), "first", "replicas", "", map[string]interface{}{})
+		out, _ := r.JSON()
+		if err != nil {
+			t.Errorf("Error lookup at %s: %s", ref, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != want {
+			t.Errorf("replicas at %s is %s; want %s", ref, v, want)
+		}
+
+		again, againCommit, err := s.GitTree(repo, ref)
+		if err != nil || again != tree || againCommit != commit || len(commit) != 40 {
+			t.Errorf("Error exporting %s again: %s is %s", ref, again, tree)
+		}
+
+		trees = append(trees, tree)
+	}
+
+	s.Close()
+	for _, tree := range trees {
+		if _, err := os.Stat(tree); !os.IsNotExist(err) {
+			t.Errorf("Error %s should be removed on close: %v", tree, err)
+		}
+	}
+}
+
+func TestGitTreeInvalidRef(t *testing.T) {
+	repo := newGitRepo(t)
+	s := &Sessions{}
+	defer s.Close()
+
+	for _, ref := range []string{"doesnt_exists", "--output=/tmp/x"} {
+		if _, _, err := s.GitTree(repo, ref); err == nil {
+			t.Errorf("Error exporting %s should fail", ref)
+		}
+	}
+}
+
+func TestGitFS(t *testing.T) {
+	repo := newGitRepo(t)
+
+	fsys, _, err := GitFS(repo, "v1")
+	if err != nil {
+		t.Fatalf("Error reading v1: %s", err)
+	}
+
+	if err := fstest.TestFS(fsys, "hiera/hiera.yaml", "hiera/data/common.yaml"); err != nil {
+		t.Error(err)
+	}
+
+	// Unlike git archive, files with the export-ignore attribute are read
+	if b, err := fs.ReadFile(fsys, "hiera/data/ignored.yaml"); err != nil || string(b) != "ignored: true\n" {
+		t.Errorf("Error reading an export-ignore file: %q %v", b, err)
+	}
+}
@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceHash returns a SHA-256 digest over the paths and contents of the data
+// files in sources, which are resolved relative to the directory of config.
+// Directories, like those terraform_state_data reads, contribute every file
+// below them. Sources without a data file only contribute their level name,
+// those served over HTTP(S) their level name and URI.
+func SourceHash(config string, sources []Source) (string, error) {
+	h := sha256.New()
+	for _, source := range sources {
+		fmt.Fprintf(h, "%s\x00%s\x00", source.Level, source.Path)
+		if source.Path == "" || strings.Contains(source.Path, "://") {
+			continue
+		}
+
+		path := source.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(config), path)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		if !info.IsDir() {
+			if err := hashFile(h, path); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+
+			rel, _ := filepath.Rel(path, file)
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+			return hashFile(h, file)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the length and the content of the file at path to h
+func hashFile(h io.Writer, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(h, "%d\x00", len(content))
+	_, err = h.Write(content)
+	return err
+}
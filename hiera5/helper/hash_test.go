@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceHash(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "hiera.yaml")
+	sources := []Source{{Level: "Common", Path: "common.yaml"}}
+
+	if err := os.WriteFile(filepath.Join(dir, "common.yaml"), []byte("a: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h1, err := SourceHash(config, sources)
+	if err != nil {
+		t.Errorf("Error hashing sources: %s", err)
+	}
+
+	h2, _ := SourceHash(config, sources)
+	if h1 != h2 {
+		t.Errorf("Hash of unchanged sources is %s; want %s", h2, h1)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "common.yaml"), []byte("a: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h3, _ := SourceHash(config, sources)
+	if h3 == h1 {
+		t.Errorf("Hash of changed sources is unchanged: %s", h3)
+	}
+
+	if _, err := SourceHash(config, []Source{{Level: "Common", Path: "doesnt_exists.yaml"}}); err == nil {
+		t.Errorf("Error missing data file should return an error")
+	}
+
+	if _, err := SourceHash(config, []Source{{Level: "Remote", Path: "https://example.com/common.yaml"}}); err != nil {
+		t.Errorf("Error hashing a remote source: %s", err)
+	}
+}
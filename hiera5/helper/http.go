@@ -0,0 +1,179 @@
+package helper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/dgoyaml/yaml"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// httpData is a data_hash function that fetches the YAML or JSON hash served at
+// the uri of the hierarchy level. The headers option adds request headers and
+// the ca_file option a PEM file of certificate authorities to trust, resolved
+// against the directory of the hiera config when relative. hiera calls data_hash
+// functions again for every lookup, so responses are cached for the session and
+// revalidated with their ETag. A 404 response skips the level.
+func httpData(pc sdk.ProviderContext) dgo.Map {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return vf.Map()
+	}
+
+	pv := sc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`uri`))
+	}
+	uri := pv.String()
+
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		panic(fmt.Errorf("invalid uri %s: %s", redactURL(uri), err.Error()))
+	}
+	req.Header.Set("Accept", "application/yaml, application/json;q=0.9, */*;q=0.1")
+
+	if hv, ok := sc.Option(`headers`).(dgo.Map); ok {
+		hv.EachEntry(func(e dgo.MapEntry) {
+			req.Header.Set(e.Key().String(), e.Value().String())
+		})
+	}
+
+	cacheKey := `http::` + uri
+	var cached dgo.Map
+	if cv, ok := sc.CachedValue(cacheKey); ok {
+		cached = cv.(dgo.Map)
+		req.Header.Set("If-None-Match", cached.Get(`etag`).String())
+	}
+
+	resp, err := httpDataClient(sc).Do(req)
+	if err != nil {
+		panic(fmt.Errorf("could not fetch %s: %s", redactURL(uri), err.Error()))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached.Get(`data`).(dgo.Map)
+	case resp.StatusCode == http.StatusNotFound:
+		return vf.Map()
+	case resp.StatusCode != http.StatusOK:
+		panic(fmt.Errorf("could not fetch %s: %s", redactURL(uri), resp.Status))
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(fmt.Errorf("could not fetch %s: %s", redactURL(uri), err.Error()))
+	}
+
+	data := decodeHTTPData(uri, resp.Header.Get("Content-Type"), b)
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		sc.Cache(cacheKey, vf.Map(`etag`, etag, `data`, data))
+	}
+
+	return data
+}
+
+// httpDataClient returns the client trusting the certificate authorities of
+// the ca_file option, if any
+func httpDataClient(sc api.ServerContext) *http.Client {
+	cv := sc.Option(`ca_file`)
+	if cv == nil {
+		return httpClient
+	}
+
+	return caFileClient(sc, configRelative(sc, cv.String()))
+}
+
+// caFileClient returns the client trusting the certificate authorities in the
+// PEM file path, created once per session so that levels sharing a CA file
+// share connections. Its idle connections are closed with the session, see
+// closeCached.
+func caFileClient(sc api.ServerContext, path string) *http.Client {
+	cache := sc.Invocation().TopProviderCache()
+	cacheKey := `http::ca_file::` + path
+
+	if v, ok := cache.Load(cacheKey); ok {
+		return v.(dgo.Native).GoValue().(*http.Client)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		panic(fmt.Errorf("%s contains no PEM encoded certificate", path))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	c, _ := cache.LoadOrStore(cacheKey, vf.Value(&http.Client{Timeout: httpClient.Timeout, Transport: transport}))
+
+	return c.(dgo.Native).GoValue().(*http.Client)
+}
+
+// decodeHTTPData decodes the hash in b according to its content type, falling
+// back on the extension of uri when the content type is generic
+func decodeHTTPData(uri string, contentType string, b []byte) dgo.Map {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	format := ""
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		format = "json"
+	case strings.HasSuffix(mediaType, "/yaml") || strings.HasSuffix(mediaType, "/x-yaml") || strings.HasSuffix(mediaType, "+yaml"):
+		format = "yaml"
+	case mediaType == "" || mediaType == "text/plain" || mediaType == "application/octet-stream":
+		format = "yaml"
+		if u, err := url.Parse(uri); err == nil && strings.EqualFold(filepath.Ext(u.Path), ".json") {
+			format = "json"
+		}
+	default:
+		panic(fmt.Errorf("unsupported content type %s of %s", mediaType, redactURL(uri)))
+	}
+
+	var v dgo.Value
+	if format == "json" {
+		v = streamer.UnmarshalJSON(b, nil)
+		if data, ok := v.(dgo.Map); ok {
+			return data
+		}
+		panic(api.JSONNOtHash(redactURL(uri)))
+	}
+
+	v, err := yaml.Unmarshal(b)
+	if err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", redactURL(uri), err.Error()))
+	}
+	if data, ok := v.(dgo.Map); ok {
+		return data
+	}
+	panic(api.YamlNotHash(redactURL(uri)))
+}
+
+// redactURL removes the credentials from u, if any
+func redactURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		return parsed.Redacted()
+	}
+
+	return u
+}
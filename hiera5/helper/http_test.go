@@ -0,0 +1,201 @@
+package helper
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+)
+
+type httpDocument struct {
+	contentType string
+	body        string
+}
+
+// newHTTPDataServer serves documents over TLS and returns the scope pointing
+// the hierarchy of hiera-http.yaml to it, together with the number of full (200)
+// and not modified (304) responses served, keyed by status and path
+func newHTTPDataServer(t *testing.T, documents map[string]httpDocument) (map[string]interface{}, map[string]int) {
+	var lock sync.Mutex
+	served := map[string]int{}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		etag := `"` + r.URL.Path + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			lock.Lock()
+			served["304 "+r.URL.Path]++
+			lock.Unlock()
+
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		lock.Lock()
+		served["200 "+r.URL.Path]++
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", doc.contentType)
+		_, _ = w.Write([]byte(doc.body))
+	}))
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]interface{}{"base_url": srv.URL, "ca_file": caFile, "service": "api", "environment": "live"}, served
+}
+
+var httpDocuments = map[string]httpDocument{
+	"/service/api.yaml":      {"application/yaml", "replicas: 3\n"},
+	"/environment/live.json": {"application/json; charset=utf-8", `{"replicas": 2, "region": "eu-west-1", "tags": {"env": "live"}}`},
+	"/common.yaml":           {"text/plain", "tags:\n  team: core\nregion: us-east-1\nreplicas: 1\n"},
+	"/defaults":              {"", "timeout: 30\n"},
+}
+
+func TestLookupHTTP(t *testing.T) {
+	scope, _ := newHTTPDataServer(t, httpDocuments)
+
+	for _, tc := range []struct {
+		key      string
+		strategy string
+		want     string
+		levels   []string
+	}{
+		{"replicas", "first", `3`, []string{"Service"}},
+		{"region", "first", `"eu-west-1"`, []string{"Environment"}},
+		{"tags", "deep", `{"env":"live","team":"core"}`, []string{"Environment", "Common"}},
+		{"timeout", "first", `30`, []string{"Common"}},
+	} {
+		r, err := Lookup(context.TODO(), "../test-fixtures/hiera-http.yaml", tc.strategy, tc.key, "", scope)
+		out, _ := r.JSON()
+		sources := r.Sources
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", tc.key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != tc.want {
+			t.Errorf("%s is %s; want %s", tc.key, v, tc.want)
+		}
+
+		if len(sources) != len(tc.levels) {
+			t.Errorf("%s sources are %v; want levels %v", tc.key, sources, tc.levels)
+			continue
+		}
+
+		for i, level := range tc.levels {
+			if sources[i].Level != level || !strings.HasPrefix(sources[i].Path, scope["base_url"].(string)) {
+				t.Errorf("%s sources are %v; want levels %v", tc.key, sources, tc.levels)
+			}
+		}
+	}
+}
+
+func TestLookupHTTPNotFound(t *testing.T) {
+	scope, _ := newHTTPDataServer(t, httpDocuments)
+	scope["service"] = "worker"
+
+	r, err := Lookup(context.TODO(), "../test-fixtures/hiera-http.yaml", "first", "replicas", "", scope)
+	out, _ := r.JSON()
+	sources := r.Sources
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if v := strings.TrimSpace(string(out)); v != `2` || len(sources) != 1 || sources[0].Level != "Environment" {
+		t.Errorf("replicas is %s from %v; want 2 from Environment", v, sources)
+	}
+}
+
+func TestLookupHTTPErrors(t *testing.T) {
+	for name, documents := range map[string]map[string]httpDocument{
+		"content type": {"/service/api.yaml": {"text/html", "<html></html>"}},
+		"not a hash":   {"/service/api.yaml": {"application/yaml", "- replicas\n"}},
+	} {
+		scope, _ := newHTTPDataServer(t, documents)
+
+		_, err := Lookup(context.TODO(), "../test-fixtures/hiera-http.yaml", "first", "replicas", "", scope)
+		if err == nil {
+			t.Errorf("Error lookup with %s should fail", name)
+		}
+	}
+
+	scope, _ := newHTTPDataServer(t, httpDocuments)
+	scope["ca_file"] = "keys/public_key.pkcs7.pem"
+
+	_, err := Lookup(context.TODO(), "../test-fixtures/hiera-http.yaml", "first", "replicas", "", scope)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("Error lookup with an untrusted server is %v; want a certificate error", err)
+	}
+}
+
+func TestLookupHTTPCache(t *testing.T) {
+	scope, served := newHTTPDataServer(t, httpDocuments)
+
+	worker := map[string]interface{}{}
+	for k, v := range scope {
+		worker[k] = v
+	}
+	worker["service"] = "worker"
+
+	s := &Sessions{}
+	defer s.Close()
+
+	config := "../test-fixtures/hiera-http.yaml"
+	_, err := s.LookupScopes(
+		context.TODO(),
+		config,
+		"first",
+		"timeout",
+		"",
+		map[string]map[string]interface{}{"api": scope, "worker": worker},
+		false)
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	// Each scope invokes the data_hash functions anew, the second one revalidates
+	// what the first one fetched
+	for _, path := range []string{"/environment/live.json", "/common.yaml", "/defaults"} {
+		if n := served["200 "+path]; n != 1 {
+			t.Errorf("%s was served %d times; want 1", path, n)
+		}
+		if n := served["304 "+path]; n != 1 {
+			t.Errorf("%s was revalidated %d times; want 1", path, n)
+		}
+	}
+
+	var clients []*http.Client
+	s.sessions[config].TopProviderCache().Range(func(_, v interface{}) bool {
+		if n, ok := v.(dgo.Native); ok {
+			if c, ok := n.GoValue().(*http.Client); ok {
+				clients = append(clients, c)
+			}
+		}
+		return true
+	})
+	if len(clients) != 1 {
+		t.Errorf("Error levels sharing a CA file should share a client: %v", clients)
+	}
+}
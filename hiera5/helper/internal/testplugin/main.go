@@ -0,0 +1,22 @@
+// Command testplugin is a hiera plugin used by the tests of the helper package.
+// Its data_hash function reports the process id of the plugin, which tells
+// whether lookups share the same plugin process.
+package main
+
+import (
+	"os"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hierasdk/hiera"
+	"github.com/lyraproj/hierasdk/plugin"
+	"github.com/lyraproj/hierasdk/register"
+)
+
+func main() {
+	register.DataHash(`testplugin`, func(hiera.ProviderContext) dgo.Map {
+		return vf.Map(`plugin_pid`, os.Getpid(), `plugin_greeting`, `hello`)
+	})
+
+	plugin.ServeAndExit()
+}
@@ -2,82 +2,180 @@ package helper
 
 import (
 	"fmt"
-	"strings"
 
 	"context"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/typ"
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
 	"github.com/lyraproj/hiera/hiera"
 	"github.com/lyraproj/hiera/provider"
 	sdk "github.com/lyraproj/hierasdk/hiera"
 
-	"bytes"
-	"io"
 	"os"
+	"sort"
 )
 
-// Lookup is a wrapper for lyraproj's hiera/hiera.LookupAndRender
-// it returns either an empty string when key is not found or JSON encoded key's value
-func Lookup(ctx context.Context, config string, strategy string, key string, valueType string, vars map[string]interface{}) ([]byte, error) {
-	var (
-		args    []string
-		out     []byte
-		b       bytes.Buffer
-		cmdOpts hiera.CommandOptions
-	)
+// Lookup is a wrapper for lyraproj's hiera/hiera.Lookup2, it returns the value
+// of key, whether it was found, the hierarchy levels and data files it was found
+// in, whether it is Sensitive, and the explanation of the lookup
+func Lookup(ctx context.Context, config string, strategy string, key string, valueType string, vars map[string]interface{}) (Result, error) {
+	return (*Sessions)(nil).Lookup(ctx, config, strategy, key, valueType, vars)
+}
+
+// LookupScopes performs the lookup of key once for every scope in scopes, reusing
+// a single hiera session for all of them. The results are keyed like scopes and
+// are the same as Lookup's. With strictScope, every scope is first checked like
+// CheckScope does, within the same session.
+func LookupScopes(ctx context.Context, config string, strategy string, key string, valueType string, scopes map[string]map[string]interface{}, strictScope bool) (map[string]Result, error) {
+	return (*Sessions)(nil).LookupScopes(ctx, config, strategy, key, valueType, scopes, strictScope)
+}
+
+// Lookup is the same as the Lookup function, within the session of config
+func (s *Sessions) Lookup(ctx context.Context, config string, strategy string, key string, valueType string, vars map[string]interface{}) (Result, error) {
+	var result Result
+
+	err := s.withSession(ctx, config, strategy, key, valueType, func(c api.Session, options dgo.Map) {
+		result = lookup(ctx, c, config, options, key, vars)
+	})
+
+	return result, err
+}
+
+// LookupScopes is the same as the LookupScopes function, within the session of config
+func (s *Sessions) LookupScopes(ctx context.Context, config string, strategy string, key string, valueType string, scopes map[string]map[string]interface{}, strictScope bool) (map[string]Result, error) {
+	var scopeErr error
+
+	results := make(map[string]Result, len(scopes))
+
+	err := s.withSession(ctx, config, strategy, key, valueType, func(c api.Session, options dgo.Map) {
+		if strictScope {
+			labels := make([]string, 0, len(scopes))
+			for label := range scopes {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+
+			for _, label := range labels {
+				if scopeErr = checkScope(c, config, scopes[label]); scopeErr != nil {
+					return
+				}
+			}
+		}
+
+		for label, vars := range scopes {
+			results[label] = lookup(ctx, c, config, options, key, vars)
+		}
+	})
+	if scopeErr != nil {
+		return nil, scopeErr
+	}
+
+	return results, err
+}
+
+func (s *Sessions) withSession(ctx context.Context, config string, strategy string, key string, valueType string, consumer func(api.Session, dgo.Map)) error {
+	var options dgo.Map
 
 	cfgOpts := vf.MutableMap()
-	cfgOpts.Put(
-		provider.LookupKeyFunctions, []sdk.LookupKey{provider.ConfigLookupKey, provider.Environment})
+	providers := []sdk.LookupKey{provider.ConfigLookupKey, provider.Environment}
+	functions := []interface{}{
+		`eyaml_lookup_key`, vf.Value(sdk.LookupKey(eyamlLookupKey)),
+		`sops_data`, vf.Value(sdk.DataHash(sopsData)),
+		`vault_lookup_key`, vf.Value(sdk.LookupKey(vaultLookupKey)),
+		`consul_lookup_key`, vf.Value(sdk.LookupKey(consulLookupKey)),
+		`http_data_hash`, vf.Value(sdk.DataHash(httpData)),
+		`terraform_state_data`, vf.Value(sdk.DataHash(terraformStateData)),
+		`toml_data`, vf.Value(sdk.DataHash(tomlData)),
+		`hcl_data`, vf.Value(sdk.DataHash(hclData)),
+		`dotenv_data`, vf.Value(sdk.DataHash(dotenvData)),
+		`sqlite_lookup_key`, vf.Value(sdk.LookupKey(sqliteLookupKey)),
+	}
+
+	if s != nil {
+		providers = append(providers, s.ScopeProviders...)
+		for name, fn := range s.DataHashes {
+			functions = append(functions, name, vf.Value(fn))
+		}
+		for name, fn := range s.LookupKeys {
+			functions = append(functions, name, vf.Value(fn))
+		}
+		for name, fn := range s.DataDigs {
+			functions = append(functions, name, vf.Value(digLookupKey(fn)))
+		}
+	}
+
+	cfgOpts.Put(provider.LookupKeyFunctions, providers)
+	// the functions map must be frozen as it is, later entries replacing earlier ones
+	cfgOpts.Put(api.HieraFunctions, vf.Map(functions...))
 
 	tflog.Debug(ctx, fmt.Sprintf("Config file is %s", config))
 
 	if _, err := os.Stat(config); os.IsNotExist(err) {
 		tflog.Debug(ctx, fmt.Sprintf("[DEBUG] ERROR '%s' reading config %s", err.Error(), config))
-		return out, err
+		return err
 	}
 
-	cmdOpts.Merge = strategy
+	if !(strategy == "" || strategy == "first") {
+		options = vf.Map("merge", strategy)
+	}
 	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup strategy is %s", strategy))
 	cfgOpts.Put(api.HieraConfig, config)
 
 	//TODO: Implement type
-	//if valueType != "" {
-	//	cmdOpts.Type = valueType
-	//}
 	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup value type is %s", valueType))
 
 	cfgOpts.Put(api.HieraDialect, "pcore")
 
-	for key, value := range vars {
-		//tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Var: %s=%s", key, value)
-		cmdOpts.Variables = append(cmdOpts.Variables, strings.Join([]string{key, value.(string)}, "="))
+	if s != nil && s.PluginTransport != "" {
+		cfgOpts.Put(`pluginTransport`, s.PluginTransport)
 	}
 
-	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup variables are %s", cmdOpts.Variables))
+	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup key is %s", key))
 
-	cmdOpts.RenderAs = "json"
+	if s != nil {
+		return s.do(config, cfgOpts, func(c api.Session) {
+			consumer(c, options)
+		})
+	}
 
-	args = append(args, key)
-	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup key is %s", key))
-	hiera.DoWithParent(context.TODO(), provider.MuxLookupKey, cfgOpts, func(c api.Session) {
-		hiera.LookupAndRender(c, &cmdOpts, args, &b)
+	return hiera.TryWithParent(context.TODO(), provider.MuxLookupKey, cfgOpts, func(c api.Session) error {
+		defer closeCached(c)
+		consumer(c, options)
+		return nil
 	})
+}
 
-	out, _ = io.ReadAll(io.Reader(&b))
+func lookup(ctx context.Context, c api.Session, config string, options dgo.Map, key string, vars map[string]interface{}) Result {
+	var result Result
 
-	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] out is %s", string(out)))
+	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] Lookup variables are %v", vars))
+
+	scope := vf.MutableMap()
+	for key, value := range vars {
+		scope.Put(key, scopeValue(c, value.(string)))
+	}
+
+	explainer := newSourceExplainer(config)
+
+	found := hiera.Lookup2(c.Invocation(scope, explainer), []string{key}, typ.Any, nil, nil, nil, options, nil)
+	if found != nil {
+		// Sensitive values can't be handed out as is, the caller decides how to protect them
+		result.Value, result.Sensitive = unwrapSensitive(found)
+		result.Found = true
+	}
+
+	if result.Sensitive {
+		tflog.Debug(ctx, "[DEBUG] out is sensitive")
+	} else {
+		tflog.Debug(ctx, fmt.Sprintf("[DEBUG] out is %v", result.Value))
+	}
 
-	//cmdOpts.RenderAs = "yaml"
-	//cmdOpts.ExplainOptions = true
-	//cmdOpts.ExplainData = true
-	//hiera.DoWithParent(context.TODO(), provider.MuxLookupKey, cfgOpts, func(c api.Session) {
-	//	hiera.LookupAndRender(c, &cmdOpts, args, &b)
-	//})
-	//explain, _ = ioutil.ReadAll(io.Reader(&b))
-	//tflog.Debug(ctx, fmt.Sprintf("[DEBUG] explain is %s", string(explain))
+	result.Sources = explainer.Sources()
+	result.Explanation = explainer.String()
+	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] explain is %s", result.Explanation))
 
-	return out, nil
+	return result
 }
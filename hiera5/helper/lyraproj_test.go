@@ -3,6 +3,10 @@ package helper
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cast"
@@ -11,13 +15,14 @@ import (
 func TestLookupSimple(t *testing.T) {
 	var f interface{}
 
-	out, err := Lookup(
+	r, err := Lookup(
 		context.TODO(),
 		"../test-fixtures/hiera.yaml",
 		"deep",
 		"is_utc",
 		"",
 		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	out, _ := r.JSON()
 	if err != nil {
 		t.Errorf("Error lookup: %s", err)
 	}
@@ -34,13 +39,14 @@ func TestLookupSimple(t *testing.T) {
 }
 
 func TestLookupInvalidConfig(t *testing.T) {
-	out, err := Lookup(
+	r, err := Lookup(
 		context.TODO(),
 		"../doesnt_exists/hiera.yaml",
 		"deep",
 		"is_utc",
 		"",
 		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	out, _ := r.JSON()
 	if err == nil {
 		t.Errorf("Error invalid config should not return: %s", out)
 	}
@@ -49,13 +55,14 @@ func TestLookupInvalidConfig(t *testing.T) {
 func TestLookupEmptyString(t *testing.T) {
 	var f interface{}
 
-	out, err := Lookup(
+	r, err := Lookup(
 		context.TODO(),
 		"../test-fixtures/hiera.yaml",
 		"deep",
 		"empty_string",
 		"",
 		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	out, _ := r.JSON()
 	if err != nil {
 		t.Errorf("Error lookup: %s", err)
 	}
@@ -69,16 +76,323 @@ func TestLookupEmptyString(t *testing.T) {
 }
 
 func TestLookupNonExistant(t *testing.T) {
-	out, err := Lookup(
+	r, err := Lookup(
 		context.TODO(),
 		"../test-fixtures/hiera.yaml",
 		"deep",
 		"doesnt_exists",
 		"",
 		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	out, _ := r.JSON()
 	if err != nil {
 		t.Errorf("Error lookup: %s", err)
 	}
 
 	_ = cast.ToString(out)
 }
+
+func TestLookupSources(t *testing.T) {
+	r, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"aws_tags",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	sources := r.Sources
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	want := []Source{
+		{Level: "Service", Path: "hieradata/service/api.yaml"},
+		{Level: "Environment", Path: "hieradata/environment/live.yaml"},
+		{Level: "Common", Path: "hieradata/common.yaml"},
+	}
+
+	if len(sources) != len(want) {
+		t.Fatalf("sources are %v; want %v", sources, want)
+	}
+
+	for i := range want {
+		if sources[i] != want[i] {
+			t.Errorf("sources[%d] is %v; want %v", i, sources[i], want[i])
+		}
+	}
+}
+
+func TestLookupSourcesFirst(t *testing.T) {
+	r, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"first",
+		"aws_instance_size",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	sources := r.Sources
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if len(sources) != 1 || sources[0] != (Source{Level: "Service", Path: "hieradata/service/api.yaml"}) {
+		t.Errorf("sources are %v; want %v", sources, []Source{{Level: "Service", Path: "hieradata/service/api.yaml"}})
+	}
+}
+
+func TestLookupScopes(t *testing.T) {
+	results, err := LookupScopes(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"aws_instance_size",
+		"",
+		map[string]map[string]interface{}{
+			"api":    {"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"},
+			"worker": {"service": "worker", "environment": "live", "facts": "{timezone=>'CET'}"},
+		},
+		false)
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	want := map[string]string{"api": `"t2.large"`, "worker": `"t2.micro"`}
+	for label, v := range want {
+		if out, _ := results[label].JSON(); string(out) != v {
+			t.Errorf("aws_instance_size in %s is %s; want %s", label, out, v)
+		}
+	}
+}
+
+func TestLookupScopesNonExistant(t *testing.T) {
+	results, err := LookupScopes(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"doesnt_exists",
+		"",
+		map[string]map[string]interface{}{"api": {"service": "api", "environment": "live"}},
+		false)
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if results["api"].Found {
+		t.Errorf("Error non existent key should not be found: %v", results["api"].Value)
+	}
+}
+
+func TestLookupScopesStrictScope(t *testing.T) {
+	_, err := LookupScopes(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"aws_instance_size",
+		"",
+		map[string]map[string]interface{}{
+			"api":    {"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"},
+			"worker": {"service": "worker", "environment": "live"},
+		},
+		true)
+
+	var scopeErr *ScopeError
+	if !errors.As(err, &scopeErr) || scopeErr.Variable != "facts.timezone" {
+		t.Errorf("Error lookup with undefined facts.timezone should fail: %v", err)
+	}
+}
+
+func TestLookupSensitive(t *testing.T) {
+	var f interface{}
+
+	r, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"db_password",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	out, _ := r.JSON()
+	sensitive := r.Sensitive
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	err = json.Unmarshal(out, &f)
+	if err != nil {
+		t.Errorf("Error unmarshalling JSON: %s", err)
+	}
+
+	if f != "s3cr3t" || !sensitive {
+		t.Errorf("db_password is %v, sensitive %t; want %s, sensitive %t", f, sensitive, "s3cr3t", true)
+	}
+
+	r, _ = Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		"deep",
+		"aws_instance_size",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	sensitive = r.Sensitive
+	if sensitive {
+		t.Errorf("aws_instance_size should not be sensitive")
+	}
+}
+
+func TestLookupEyaml(t *testing.T) {
+	for key, want := range map[string]string{
+		"api_key":       `"k3y"`,
+		"db_url":        `"postgres://app:pa55@db/app"`,
+		"secret_list":   `["first","public"]`,
+		"plain_setting": `"visible"`,
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera.yaml",
+			"first",
+			key,
+			"",
+			map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+		out, _ := r.JSON()
+		sources, sensitive := r.Sources, r.Sensitive
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != want {
+			t.Errorf("%s is %s; want %s", key, v, want)
+		}
+
+		if sensitive != (key != "plain_setting") {
+			t.Errorf("%s sensitive is %t", key, sensitive)
+		}
+
+		if len(sources) != 1 || sources[0].Level != "Secrets" || sources[0].Path != "hieradata/secrets.eyaml" {
+			t.Errorf("%s sources are %v", key, sources)
+		}
+	}
+}
+
+func TestLookupSops(t *testing.T) {
+	for key, want := range map[string]string{
+		"sops_password":           `"s0ps"`,
+		"sops_port":               `5432`,
+		"sops_replicas":           `["alpha","beta"]`,
+		"sops_settings":           `{"enabled":true,"ratio":0.5}`,
+		"sops_public_unencrypted": `"visible"`,
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera.yaml",
+			"first",
+			key,
+			"",
+			map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+		out, _ := r.JSON()
+		sources, sensitive := r.Sources, r.Sensitive
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != want {
+			t.Errorf("%s is %s; want %s", key, v, want)
+		}
+
+		if sensitive != (key != "sops_public_unencrypted") {
+			t.Errorf("%s sensitive is %t", key, sensitive)
+		}
+
+		if len(sources) != 1 || sources[0].Level != "Sops" || sources[0].Path != "hieradata/secrets.sops.yaml" {
+			t.Errorf("%s sources are %v", key, sources)
+		}
+	}
+}
+
+func TestLookupTerraformState(t *testing.T) {
+	for key, want := range map[string]string{
+		"network":           `{"vpc_id":"vpc-0a1b2c3d","subnet_ids":["subnet-01","subnet-02"]}`,
+		"db_endpoint":       `"db.live.internal:5432"`,
+		"db_admin_password": `"t3rr4form"`,
+		"vpc_id":            `"vpc-0a1b2c3d"`,
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera.yaml",
+			"first",
+			key,
+			"",
+			map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+		out, _ := r.JSON()
+		sources, sensitive := r.Sources, r.Sensitive
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != want {
+			t.Errorf("%s is %s; want %s", key, v, want)
+		}
+
+		if sensitive != (key == "db_admin_password") {
+			t.Errorf("%s sensitive is %t", key, sensitive)
+		}
+
+		if key != "vpc_id" && (len(sources) != 1 || sources[0] != (Source{Level: "Terraform State", Path: "hieradata/tfstate/live.tfstate"})) {
+			t.Errorf("%s sources are %v", key, sources)
+		}
+	}
+}
+
+func TestLookupResult(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hiera.yaml"), []byte("version: 5\nhierarchy:\n  - name: Common\n    path: common.yaml\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "common.yaml"), []byte("nothing: ~\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Lookup(context.TODO(), filepath.Join(dir, "hiera.yaml"), "first", "nothing", "", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Error lookup: %s", err)
+	}
+
+	if out, _ := r.JSON(); !r.Found || string(out) != "null" || len(r.Sources) != 1 || r.Explanation == "" {
+		t.Errorf("Error null value should be found: %t %s %v %q", r.Found, out, r.Sources, r.Explanation)
+	}
+
+	r, err = Lookup(context.TODO(), filepath.Join(dir, "hiera.yaml"), "first", "doesnt_exists", "", map[string]interface{}{})
+	if out, _ := r.JSON(); err != nil || r.Found || out != nil || r.Value != nil {
+		t.Errorf("Error non existent key should not be found: %t %s %v", r.Found, out, err)
+	}
+}
+
+func TestLookupTypes(t *testing.T) {
+	for _, tc := range []struct {
+		key       string
+		want      string
+		sensitive bool
+	}{
+		{"tls_certificate", `"aGllcmE1IGNlcnRpZmljYXRl"`, false},
+		{"released_at", `"2024-05-01T10:00:00Z"`, false},
+		{"api_key", `"k3y"`, true},
+		{"large_id", `9007199254740993`, false},
+		{"ratio", `0.25`, false},
+	} {
+		r, err := Lookup(context.TODO(), "../test-fixtures/hiera-types.yaml", "first", tc.key, "", map[string]interface{}{"environment": "live"})
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", tc.key, err)
+			continue
+		}
+
+		out, err := r.JSON()
+		if err != nil || string(out) != tc.want {
+			t.Errorf("%s is %s, %v; want %s", tc.key, out, err, tc.want)
+		}
+
+		if r.Sensitive != tc.sensitive {
+			t.Errorf("%s sensitive is %t; want %t", tc.key, r.Sensitive, tc.sensitive)
+		}
+	}
+}
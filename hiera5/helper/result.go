@@ -0,0 +1,74 @@
+package helper
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/tf"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/dgo/vf"
+)
+
+// Result is the outcome of the lookup of a key
+type Result struct {
+	// Value is the value found, with every Sensitive within it unwrapped. It is
+	// nil when the key isn't found and vf.Nil when it is found to be null.
+	Value dgo.Value
+	// Found reports whether the key was found
+	Found bool
+	// Sources are the hierarchy levels and data files the value was found in
+	Sources []Source
+	// Sensitive reports whether the value, or any part of it, is Sensitive
+	Sensitive bool
+	// Explanation is hiera's account of how the value was looked up
+	Explanation string
+}
+
+// JSON returns the JSON encoded value, or nil when the key isn't found. Binary
+// values are encoded as base64 strings and Timestamps as RFC 3339 strings.
+func (r Result) JSON() ([]byte, error) {
+	var b bytes.Buffer
+
+	if !r.Found {
+		return nil, nil
+	}
+
+	err := util.Catch(func() {
+		if r.Value.Equals(vf.Nil) {
+			b.WriteString("null")
+			return
+		}
+
+		opts := streamer.DefaultOptions()
+		opts.DedupLevel = streamer.NoDedup
+		streamer.New(tf.DefaultAliases(), opts).Stream(plainData(r.Value), streamer.JSON(&b))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// plainData returns value with every Binary within it replaced by its base64
+// encoding and every Timestamp by its RFC 3339 form, which JSON has no types for
+func plainData(value dgo.Value) dgo.Value {
+	switch v := value.(type) {
+	case dgo.Binary:
+		return vf.String(v.Encode())
+	case dgo.Time:
+		return vf.String(v.GoTime().Format(time.RFC3339Nano))
+	case dgo.Array:
+		return v.Map(func(e dgo.Value) interface{} {
+			return plainData(e)
+		})
+	case dgo.Map:
+		return v.Map(func(e dgo.MapEntry) interface{} {
+			return plainData(e.Value())
+		})
+	}
+
+	return value
+}
@@ -0,0 +1,155 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaError is returned when a looked up value does not validate against a JSON Schema
+type SchemaError struct {
+	Key        string
+	Schema     string
+	Violations []SchemaViolation
+	Sources    []Source
+	Err        error
+}
+
+// SchemaViolation describes a single location of the value that does not validate
+type SchemaViolation struct {
+	// Pointer is the JSON pointer to the offending part of the value
+	Pointer string
+	// Location is the absolute location of the failing schema keyword
+	Location string
+	Message  string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("key '%s' could not be validated against schema %s: %s", e.Key, e.Schema, e.Err)
+	}
+
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.String())
+	}
+
+	return fmt.Sprintf("key '%s' does not validate against schema %s: %s", e.Key, e.Schema, strings.Join(msgs, "; "))
+}
+
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("'%s': %s (%s)", v.Pointer, v.Message, v.Location)
+}
+
+// IsInlineSchema reports whether schema holds a JSON Schema document rather than a path to one
+func IsInlineSchema(schema string) bool {
+	schema = strings.TrimSpace(schema)
+
+	return strings.HasPrefix(schema, "{") || schema == "true" || schema == "false"
+}
+
+// compileSchema compiles the inline JSON Schema document or the schema file
+// schema, or returns it from the schemas of s when it was compiled before, so
+// that matrix and diff don't compile the same schema for every scope. Schemas
+// are cached by their inline document or absolute path until Close is called,
+// a nil *Sessions compiles them every time.
+func (s *Sessions) compileSchema(schema string) (*jsonschema.Schema, error) {
+	source := schema
+	if !IsInlineSchema(schema) {
+		var err error
+		if source, err = filepath.Abs(schema); err != nil {
+			return nil, err
+		}
+	}
+
+	if s != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if compiled, ok := s.schemas[source]; ok {
+			return compiled, nil
+		}
+	}
+
+	var (
+		compiled *jsonschema.Schema
+		err      error
+	)
+
+	if IsInlineSchema(schema) {
+		compiled, err = jsonschema.CompileString("inline.json", schema)
+	} else {
+		compiled, err = jsonschema.Compile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s != nil {
+		if s.schemas == nil {
+			s.schemas = make(map[string]*jsonschema.Schema)
+		}
+		s.schemas[source] = compiled
+	}
+
+	return compiled, nil
+}
+
+// Validate validates the JSON encoded value of key against schema, which is
+// either an inline JSON Schema document or the path to one
+func Validate(key string, schema string, value []byte) error {
+	return (*Sessions)(nil).Validate(key, schema, value)
+}
+
+// Validate is the same as the Validate function, compiling schema once for s
+func (s *Sessions) Validate(key string, schema string, value []byte) error {
+	name := "inline"
+	if !IsInlineSchema(schema) {
+		name = schema
+	}
+
+	compiled, err := s.compileSchema(schema)
+	if err != nil {
+		return &SchemaError{Key: key, Schema: name, Err: err}
+	}
+
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(value))
+	d.UseNumber()
+	if err = d.Decode(&v); err != nil {
+		return &SchemaError{Key: key, Schema: name, Err: err}
+	}
+
+	var ve *jsonschema.ValidationError
+	if err = compiled.Validate(v); errors.As(err, &ve) {
+		return &SchemaError{Key: key, Schema: name, Violations: schemaViolations(ve)}
+	} else if err != nil {
+		return &SchemaError{Key: key, Schema: name, Err: err}
+	}
+
+	return nil
+}
+
+// schemaViolations flattens the tree of validation errors into its leaves
+func schemaViolations(ve *jsonschema.ValidationError) []SchemaViolation {
+	if len(ve.Causes) == 0 {
+		return []SchemaViolation{{
+			Pointer:  ve.InstanceLocation,
+			Location: ve.AbsoluteKeywordLocation,
+			Message:  ve.Message,
+		}}
+	}
+
+	var violations []SchemaViolation
+	for _, cause := range ve.Causes {
+		violations = append(violations, schemaViolations(cause)...)
+	}
+
+	return violations
+}
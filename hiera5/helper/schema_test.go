@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateSchemaFile(t *testing.T) {
+	err := Validate("aws_tags", "../test-fixtures/schemas/aws_tags.json", []byte(`{"team":"A","tier":1}`))
+	if err != nil {
+		t.Errorf("Error validating aws_tags: %s", err)
+	}
+
+	var schemaErr *SchemaError
+
+	err = Validate("aws_tags", "../test-fixtures/schemas/aws_tags.json", []byte(`{"team":"A","tier":4}`))
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 {
+		t.Fatalf("Error invalid aws_tags should return one violation: %v", err)
+	}
+
+	if schemaErr.Violations[0].Pointer != "/tier" {
+		t.Errorf("Violation pointer is %s; want %s", schemaErr.Violations[0].Pointer, "/tier")
+	}
+}
+
+func TestValidateSchemaInline(t *testing.T) {
+	var schemaErr *SchemaError
+
+	err := Validate("java_opts", `{"type":"array","items":{"type":"string","pattern":"^-"}}`, []byte(`["-Xms512m","Xmx2g"]`))
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 {
+		t.Fatalf("Error invalid java_opts should return one violation: %v", err)
+	}
+
+	if schemaErr.Violations[0].Pointer != "/1" {
+		t.Errorf("Violation pointer is %s; want %s", schemaErr.Violations[0].Pointer, "/1")
+	}
+}
+
+func TestValidateSchemaInvalid(t *testing.T) {
+	var schemaErr *SchemaError
+
+	err := Validate("aws_tags", "../test-fixtures/schemas/doesnt_exists.json", []byte(`{}`))
+	if !errors.As(err, &schemaErr) || schemaErr.Err == nil {
+		t.Errorf("Error missing schema should return an error: %v", err)
+	}
+}
+
+func TestCompileSchemaCache(t *testing.T) {
+	s := &Sessions{}
+	defer s.Close()
+
+	s1, err := s.compileSchema("../test-fixtures/schemas/aws_tags.json")
+	if err != nil {
+		t.Fatalf("Error compiling aws_tags schema: %s", err)
+	}
+
+	s2, _ := s.compileSchema("../test-fixtures/../test-fixtures/schemas/aws_tags.json")
+	if s1 != s2 {
+		t.Errorf("Error schema file should be compiled once")
+	}
+
+	i1, _ := s.compileSchema(`{"type":"string"}`)
+	i2, _ := s.compileSchema(`{"type":"string"}`)
+	if i1 == nil || i1 != i2 {
+		t.Errorf("Error inline schema should be compiled once")
+	}
+
+	// Schemas are compiled again by other sessions and once closed
+	other := &Sessions{}
+	defer other.Close()
+
+	if s3, _ := other.compileSchema("../test-fixtures/schemas/aws_tags.json"); s3 == nil || s3 == s1 {
+		t.Errorf("Error schema file should be compiled again by other sessions")
+	}
+
+	s.Close()
+	if s4, _ := s.compileSchema("../test-fixtures/schemas/aws_tags.json"); s4 == nil || s4 == s1 {
+		t.Errorf("Error schema file should be compiled again once closed")
+	}
+}
@@ -0,0 +1,175 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/typ"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	hieraconfig "github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// ScopeError is returned when the lookup scope lacks a variable that is either
+// required or referenced by the hierarchy
+type ScopeError struct {
+	Variable string
+	Level    string
+	Location string
+}
+
+func (e *ScopeError) Error() string {
+	if e.Level == "" {
+		return fmt.Sprintf("required scope variable '%s' is not defined", e.Variable)
+	}
+
+	return fmt.Sprintf("scope variable '%s' referenced by hierarchy level '%s' (%s) is not defined", e.Variable, e.Level, e.Location)
+}
+
+var (
+	interpolation = regexp.MustCompile(`%{[^}]*}`)
+	scopeMethod   = regexp.MustCompile(`^scope\((?:"([^"]+)"|'([^']+)')\)$`)
+	anyMethod     = regexp.MustCompile(`^\w+\(.*\)$`)
+)
+
+// CheckScope verifies that every scope variable interpolated by the locations
+// and the options of the hierarchy levels in config resolves in vars. Hiera
+// itself silently replaces undefined variables with an empty string.
+func CheckScope(ctx context.Context, config string, vars map[string]interface{}) error {
+	cfgOpts := vf.MutableMap()
+	cfgOpts.Put(api.HieraConfig, config)
+	cfgOpts.Put(api.HieraDialect, "pcore")
+
+	return hiera.TryWithParent(ctx, provider.ConfigLookupKey, cfgOpts, func(c api.Session) error {
+		return checkScope(c, config, vars)
+	})
+}
+
+// checkScope is CheckScope within the session c, reading config as the lookups
+// of c do
+func checkScope(c api.Session, config string, vars map[string]interface{}) error {
+	scope := vf.MutableMap()
+	for key, value := range vars {
+		scope.Put(key, scopeValue(c, value.(string)))
+	}
+
+	ic := c.Invocation(scope, nil)
+	check := func(level string, location string, str string) error {
+		for _, expr := range scopeExpressions(str) {
+			if ic.InterpolateInScope(expr, true) == nil {
+				return &ScopeError{Variable: expr, Level: level, Location: location}
+			}
+		}
+		return nil
+	}
+
+	cfg := hieraConfig(c, config)
+	if err := checkOptions(cfg.Defaults().Options(), "defaults", check); err != nil {
+		return err
+	}
+
+	for _, he := range append(cfg.Hierarchy(), cfg.DefaultHierarchy()...) {
+		for _, loc := range he.Locations() {
+			if err := check(he.Name(), loc.Original(), loc.Original()); err != nil {
+				return err
+			}
+		}
+
+		if err := checkOptions(he.Options(), he.Name(), check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOptions calls check with the strings found in the options of level,
+// located by the option they are found in
+func checkOptions(options dgo.Map, level string, check func(level string, location string, str string) error) error {
+	var (
+		err  error
+		walk func(location string, v dgo.Value)
+	)
+
+	walk = func(location string, v dgo.Value) {
+		switch v := v.(type) {
+		case dgo.String:
+			if err == nil {
+				err = check(level, location+": "+v.GoString(), v.GoString())
+			}
+		case dgo.Array:
+			v.Each(func(e dgo.Value) { walk(location, e) })
+		case dgo.Map:
+			v.EachEntry(func(e dgo.MapEntry) { walk(location+"."+e.Key().String(), e.Value()) })
+		}
+	}
+
+	if options != nil {
+		options.EachEntry(func(e dgo.MapEntry) { walk(e.Key().String(), e.Value()) })
+	}
+
+	return err
+}
+
+// hieraConfig returns the config at path of the session c, loaded and cached
+// like the lookups of c do when none did yet
+func hieraConfig(c api.Session, path string) api.Config {
+	if v, ok := c.SharedCache().Load(hieraConfigsPrefix + path); ok {
+		if cfg, ok := v.(api.Config); ok {
+			return cfg
+		}
+	}
+
+	cfg := hieraconfig.New(path)
+	if v, loaded := c.SharedCache().LoadOrStore(hieraConfigsPrefix+path, cfg); loaded {
+		if loadedCfg, ok := v.(api.Config); ok {
+			return loadedCfg
+		}
+	}
+
+	return cfg
+}
+
+// scopeExpressions returns the scope variables interpolated in the given string,
+// ignoring lookup, alias and literal interpolations
+func scopeExpressions(str string) []string {
+	var exprs []string
+	for _, match := range interpolation.FindAllString(str, -1) {
+		expr := strings.TrimSpace(match[2 : len(match)-1])
+		if m := scopeMethod.FindStringSubmatch(expr); m != nil {
+			expr = m[1] + m[2]
+		} else if anyMethod.MatchString(expr) {
+			continue
+		}
+
+		expr = strings.Trim(expr, `"'`)
+		if expr == "" || expr == "::" {
+			continue
+		}
+
+		exprs = append(exprs, expr)
+	}
+
+	return exprs
+}
+
+// scopeValue parses a scope variable the same way hiera parses command line variables
+func scopeValue(c api.Session, value string) dgo.Value {
+	value = strings.TrimSpace(value)
+	for _, pfx := range []string{`{`, `[`, `"`, `'`} {
+		if strings.HasPrefix(value, pfx) {
+			var v dgo.Value
+			c.AliasMap().Collect(func(aa dgo.AliasAdder) {
+				v = typ.ExactValue(c.Dialect().ParseType(aa, vf.String(value)))
+			})
+			return v
+		}
+	}
+
+	return vf.String(value)
+}
@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckScope(t *testing.T) {
+	err := CheckScope(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"})
+	if err != nil {
+		t.Errorf("Error checking scope: %s", err)
+	}
+}
+
+func TestCheckScopeMissingVariable(t *testing.T) {
+	var scopeErr *ScopeError
+
+	err := CheckScope(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		map[string]interface{}{"service": "api", "facts": "{timezone=>'CET'}"})
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("Error missing environment should return a ScopeError: %v", err)
+	}
+
+	if scopeErr.Variable != "environment" || scopeErr.Level != "Environment" {
+		t.Errorf("ScopeError is %s/%s; want %s/%s", scopeErr.Variable, scopeErr.Level, "environment", "Environment")
+	}
+}
+
+func TestCheckScopeMissingNestedVariable(t *testing.T) {
+	var scopeErr *ScopeError
+
+	err := CheckScope(
+		context.TODO(),
+		"../test-fixtures/hiera.yaml",
+		map[string]interface{}{"service": "api", "environment": "live", "facts": "{region=>'eu'}"})
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("Error missing facts.timezone should return a ScopeError: %v", err)
+	}
+
+	if scopeErr.Variable != "facts.timezone" || scopeErr.Level != "Time Zone" {
+		t.Errorf("ScopeError is %s/%s; want %s/%s", scopeErr.Variable, scopeErr.Level, "facts.timezone", "Time Zone")
+	}
+}
+
+func TestCheckScopeMissingOptionVariable(t *testing.T) {
+	var scopeErr *ScopeError
+
+	err := CheckScope(
+		context.TODO(),
+		"../test-fixtures/hiera-vault.yaml",
+		map[string]interface{}{"service": "api"})
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("Error missing environment in secret_path should return a ScopeError: %v", err)
+	}
+
+	if scopeErr.Variable != "environment" || scopeErr.Level != "Vault" || !strings.HasPrefix(scopeErr.Location, "secret_path: ") {
+		t.Errorf("ScopeError is %s/%s/%s; want %s/%s/%s", scopeErr.Variable, scopeErr.Level, scopeErr.Location, "environment", "Vault", "secret_path")
+	}
+}
+
+func TestCheckScopeInvalidConfig(t *testing.T) {
+	err := CheckScope(
+		context.TODO(),
+		"../test-fixtures/hieradata/common.yaml",
+		map[string]interface{}{})
+	if err == nil {
+		t.Errorf("Error invalid config should return an error")
+	}
+}
+
+func TestScopeExpressions(t *testing.T) {
+	exprs := scopeExpressions(`%{environment}/%{ facts.timezone }/%{scope('service')}/%{lookup('x')}/%{literal('%')}/%{}`)
+	want := []string{"environment", "facts.timezone", "service"}
+
+	if len(exprs) != len(want) {
+		t.Fatalf("scopeExpressions returned %v; want %v", exprs, want)
+	}
+
+	for i := range want {
+		if exprs[i] != want[i] {
+			t.Errorf("exprs[%d] is %s; want %s", i, exprs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"github.com/lyraproj/dgo/dgo"
+)
+
+// unwrapSensitive returns value with every Sensitive within it replaced by the
+// value it wraps, and whether value contained any Sensitive at all. Sensitive
+// values are produced by lookup_options `convert_to: Sensitive` and by the
+// backends decrypting data.
+func unwrapSensitive(value dgo.Value) (dgo.Value, bool) {
+	sensitive := false
+
+	switch v := value.(type) {
+	case dgo.Sensitive:
+		u, _ := unwrapSensitive(v.Unwrap())
+		return u, true
+	case dgo.Array:
+		a := v.Map(func(e dgo.Value) interface{} {
+			u, s := unwrapSensitive(e)
+			sensitive = sensitive || s
+			return u
+		})
+		return a, sensitive
+	case dgo.Map:
+		m := v.Map(func(e dgo.MapEntry) interface{} {
+			u, s := unwrapSensitive(e.Value())
+			sensitive = sensitive || s
+			return u
+		})
+		return m, sensitive
+	}
+
+	return value, false
+}
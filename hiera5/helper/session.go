@@ -0,0 +1,119 @@
+package helper
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/hiera/session"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Sessions keeps one hiera session per config alive across lookups, so that the
+// plugins a session starts serve all of its lookups instead of being started and
+// killed for each of them, until Close is called. A nil *Sessions uses a new
+// session for every lookup.
+type Sessions struct {
+	// PluginTransport is the transport used to talk to plugins, unix or tcp.
+	// Empty selects the default of the platform.
+	PluginTransport string
+
+	// PluginDir is the directory of the plugins of the hierarchy levels that
+	// don't set plugindir, relative to the directory of the config. Empty
+	// keeps the default of hiera, plugin.
+	PluginDir string
+
+	// DataHashes, LookupKeys and DataDigs are extra functions the hierarchy
+	// levels can name as their data_hash, lookup_key or data_dig backend, by
+	// name. They replace the backends of the same name this package adds, but
+	// not those of hiera itself, like yaml_data.
+	DataHashes map[string]sdk.DataHash
+	LookupKeys map[string]sdk.LookupKey
+	DataDigs   map[string]sdk.DataDig
+
+	// ScopeProviders are extra lookup_key functions consulted after the hierarchy
+	// of the config and the env:: keys, in order. Their values are merged with
+	// the merge strategy of the lookup like those of hierarchy levels.
+	ScopeProviders []sdk.LookupKey
+
+	lock     sync.Mutex
+	sessions map[string]api.Session
+
+	// schemas are the compiled JSON Schemas, by inline document or absolute path
+	schemas map[string]*jsonschema.Schema
+
+	// trees are the directories holding the files of git revisions and
+	// bundles, below treesDir, by kind and digest
+	treesDir string
+	trees    map[string]string
+}
+
+// do calls consumer with the session of config, created with options the first
+// time, turning panics into errors like hiera.TryWithParent does
+func (s *Sessions) do(config string, options dgo.Map, consumer func(api.Session)) error {
+	return util.Catch(func() {
+		consumer(s.session(config, options))
+	})
+}
+
+func (s *Sessions) session(config string, options dgo.Map) api.Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if c, ok := s.sessions[config]; ok {
+		return c
+	}
+
+	if s.sessions == nil {
+		s.sessions = make(map[string]api.Session)
+	}
+
+	c := session.New(context.Background(), provider.MuxLookupKey, options, nil)
+	s.useConfig(c, config)
+	s.sessions[config] = c
+
+	return c
+}
+
+// Close stops the plugins started by the sessions and forgets them, later
+// lookups start new sessions. It also removes the files extracted for them.
+func (s *Sessions) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, c := range s.sessions {
+		closeCached(c)
+		c.KillPlugins()
+	}
+	s.sessions = nil
+	s.schemas = nil
+
+	if s.treesDir != "" {
+		_ = os.RemoveAll(s.treesDir)
+	}
+	s.treesDir = ""
+	s.trees = nil
+}
+
+// closeCached closes the handles the backends keep in the provider cache of c,
+// such as the databases of sqlite_lookup_key and the connections of the
+// http_data clients
+func closeCached(c api.Session) {
+	c.TopProviderCache().Range(func(_, v interface{}) bool {
+		if n, ok := v.(dgo.Native); ok {
+			switch h := n.GoValue().(type) {
+			case io.Closer:
+				_ = h.Close()
+			case interface{ CloseIdleConnections() }:
+				h.CloseIdleConnections()
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,209 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+// newPluginConfig builds internal/testplugin into the plugin directory dir of
+// a new hiera config using it, and returns that config
+func newPluginConfig(t *testing.T, plugins string) string {
+	if testing.Short() {
+		t.Skip("building the test plugin is slow")
+	}
+
+	dir := t.TempDir()
+
+	bin := filepath.Join(dir, plugins, "testplugin")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+
+	if out, err := exec.Command("go", "build", "-o", bin, "./internal/testplugin").CombinedOutput(); err != nil {
+		t.Fatalf("Error building the test plugin: %s: %s", err, out)
+	}
+
+	config := filepath.Join(dir, "hiera.yaml")
+	if err := os.WriteFile(config, []byte("version: 5\nhierarchy:\n  - name: Plugin\n    data_hash: testplugin\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestSessionsPlugin(t *testing.T) {
+	config := newPluginConfig(t, "plugin")
+
+	transports := []string{"", "tcp"}
+	if runtime.GOOS != "windows" {
+		transports = append(transports, "unix")
+	}
+
+	for _, transport := range transports {
+		sessions := &Sessions{PluginTransport: transport}
+
+		lookup := func(key string) string {
+			r, err := sessions.Lookup(context.TODO(), config, "first", key, "", map[string]interface{}{})
+			out, _ := r.JSON()
+			if err != nil {
+				t.Fatalf("Error lookup %s over %q: %s", key, transport, err)
+			}
+			return strings.TrimSpace(string(out))
+		}
+
+		if v := lookup("plugin_greeting"); v != `"hello"` {
+			t.Errorf("plugin_greeting over %q is %s; want %s", transport, v, `"hello"`)
+		}
+
+		pid := lookup("plugin_pid")
+		if again := lookup("plugin_pid"); again != pid {
+			t.Errorf("Error the plugin was restarted between lookups over %q: %s then %s", transport, pid, again)
+		}
+
+		sessions.Close()
+
+		if restarted := lookup("plugin_pid"); restarted == pid {
+			t.Errorf("Error the plugin wasn't stopped by Close over %q: %s", transport, pid)
+		}
+
+		sessions.Close()
+	}
+}
+
+func TestLookupPlugin(t *testing.T) {
+	config := newPluginConfig(t, "plugin")
+
+	pids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		r, err := Lookup(context.TODO(), config, "first", "plugin_pid", "", map[string]interface{}{})
+		out, _ := r.JSON()
+		if err != nil {
+			t.Fatalf("Error lookup: %s", err)
+		}
+		pids[string(out)] = true
+	}
+
+	if len(pids) != 2 {
+		t.Errorf("Error lookups without Sessions should each start the plugin")
+	}
+}
+
+func TestSessionsPluginDir(t *testing.T) {
+	config := newPluginConfig(t, "bin")
+
+	sessions := &Sessions{PluginDir: "bin"}
+	defer sessions.Close()
+
+	r, err := sessions.Lookup(context.TODO(), config, "first", "plugin_greeting", "", map[string]interface{}{})
+	out, _ := r.JSON()
+	if err != nil {
+		t.Fatalf("Error lookup: %s", err)
+	}
+
+	if v := strings.TrimSpace(string(out)); v != `"hello"` {
+		t.Errorf("plugin_greeting is %s; want %s", v, `"hello"`)
+	}
+
+	// The plugin directory is an option of the sessions, not of the process
+	if _, err := (&Sessions{}).Lookup(context.TODO(), config, "first", "plugin_greeting", "", map[string]interface{}{}); err == nil {
+		t.Errorf("Error lookup without PluginDir should not find the plugin")
+	}
+}
+
+func TestSessionsPluginDirDefaults(t *testing.T) {
+	dir := t.TempDir()
+
+	for content, want := range map[string]string{
+		"version: 5\n": filepath.Join(dir, "bin"),
+		"version: 5\ndefaults:\n  plugindir: custom\n": "custom",
+	} {
+		config := filepath.Join(dir, "hiera.yaml")
+		if err := os.WriteFile(config, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		sessions := &Sessions{PluginDir: "bin"}
+		if _, err := sessions.Lookup(context.TODO(), config, "first", "replicas", "", map[string]interface{}{}); err != nil {
+			t.Fatalf("Error lookup: %s", err)
+		}
+
+		cfg, _ := sessions.sessions[config].SharedCache().Load(hieraConfigsPrefix + config)
+		if c, ok := cfg.(api.Config); !ok || c.Defaults().PluginDir() != want {
+			t.Errorf("Error the default plugin directory of %q is %v; want %s", content, cfg, want)
+		}
+
+		sessions.Close()
+	}
+}
+
+func TestSessionsFunctions(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "hiera.yaml")
+	if err := os.WriteFile(config, []byte("version: 5\nhierarchy:\n  - name: Corp\n    data_hash: corp_data\n  - name: Corp Keys\n    lookup_key: corp_lookup_key\n  - name: Corp Tree\n    data_dig: corp_data_dig\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := &Sessions{
+		DataHashes: map[string]sdk.DataHash{
+			"corp_data": func(sdk.ProviderContext) dgo.Map {
+				return vf.Map("team", "platform", "region", "%{lookup('corp::region')}")
+			},
+		},
+		LookupKeys: map[string]sdk.LookupKey{
+			"corp_lookup_key": func(_ sdk.ProviderContext, key string) dgo.Value {
+				if key == "cost_center" {
+					return vf.String("cc-42")
+				}
+				return nil
+			},
+		},
+		DataDigs: map[string]sdk.DataDig{
+			"corp_data_dig": func(_ sdk.ProviderContext, key dgo.Array) dgo.Value {
+				if key.Get(0).String() == "network" {
+					return vf.Map("cidr", "10.0.0.0/16", "zones", vf.Values("a", "b"))
+				}
+				return nil
+			},
+		},
+		ScopeProviders: []sdk.LookupKey{func(_ sdk.ProviderContext, key string) dgo.Value {
+			if key == "corp::region" {
+				return vf.String("eu-west-1")
+			}
+			return nil
+		}},
+	}
+	defer sessions.Close()
+
+	for key, want := range map[string]string{
+		"team":            `"platform"`,
+		"region":          `"eu-west-1"`,
+		"cost_center":     `"cc-42"`,
+		"corp::region":    `"eu-west-1"`,
+		"network.cidr":    `"10.0.0.0/16"`,
+		"network.zones.1": `"b"`,
+	} {
+		r, err := sessions.Lookup(context.TODO(), config, "first", key, "", map[string]interface{}{})
+		out, _ := r.JSON()
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", key, err)
+			continue
+		}
+
+		if strings.TrimSpace(string(out)) != want {
+			t.Errorf("%s is %s; want %s", key, out, want)
+		}
+	}
+
+	if r, _ := Lookup(context.TODO(), config, "first", "team", "", map[string]interface{}{}); r.Found {
+		t.Errorf("Error lookups without Sessions should not know corp_data, team is %v", r.Value)
+	}
+}
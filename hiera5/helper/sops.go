@@ -0,0 +1,317 @@
+package helper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+	"gopkg.in/yaml.v3"
+)
+
+var sopsValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// sopsMetadata is the part of the sops metadata needed to decrypt a file with age
+type sopsMetadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	LastModified     string `yaml:"lastmodified"`
+	MAC              string `yaml:"mac"`
+	MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+}
+
+// sopsData is a data_hash function that reads the SOPS encrypted YAML or JSON
+// file given by the path option and decrypts it with the age identities found in
+// the file given by the age_identity_file option. Relative identity file paths
+// are resolved against the directory of the hiera config. Decrypted values are
+// Sensitive.
+func sopsData(pc sdk.ProviderContext) dgo.Map {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return vf.Map()
+	}
+
+	pv := sc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vf.Map()
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	iv := sc.Option(`age_identity_file`)
+	if iv == nil {
+		panic(api.MissingRequiredOption(`age_identity_file`))
+	}
+
+	identityFile := configRelative(sc, iv.String())
+
+	identities, err := readAgeIdentities(identityFile)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := decryptSops(b, identities)
+	if err != nil {
+		panic(fmt.Errorf("could not decrypt %s: %s", path, err.Error()))
+	}
+
+	return data
+}
+
+func readAgeIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", path, err.Error())
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse age identities in %s: %s", path, err.Error())
+	}
+
+	return identities, nil
+}
+
+// decryptSops decrypts the SOPS encrypted YAML or JSON document b, verifying its MAC
+func decryptSops(b []byte, identities []age.Identity) (dgo.Map, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("not a hash")
+	}
+	root := doc.Content[0]
+
+	var metadata *sopsMetadata
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == "sops" {
+			metadata = &sopsMetadata{}
+			if err := root.Content[i+1].Decode(metadata); err != nil {
+				return nil, fmt.Errorf("invalid sops metadata: %s", err)
+			}
+		}
+	}
+
+	if metadata == nil {
+		return nil, errors.New("sops metadata not found")
+	}
+
+	key, err := sopsDataKey(metadata, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &sopsDecrypter{key: key, hash: sha512.New(), macOnlyEncrypted: metadata.MACOnlyEncrypted}
+
+	data := vf.MapWithCapacity(len(root.Content) / 2)
+	for i := 0; i < len(root.Content); i += 2 {
+		k := root.Content[i].Value
+		if k == "sops" {
+			continue
+		}
+
+		v, err := d.decrypt(root.Content[i+1], []string{k})
+		if err != nil {
+			return nil, err
+		}
+		data.Put(k, v)
+	}
+
+	mac, _, err := d.decryptValue(metadata.MAC, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt MAC: %s", err)
+	}
+
+	if computed := fmt.Sprintf("%X", d.hash.Sum(nil)); mac != computed {
+		return nil, errors.New("MAC mismatch, the file has been tampered with")
+	}
+
+	return data, nil
+}
+
+// sopsDataKey returns the data key of the file, decrypted by the first age recipient matching identities
+func sopsDataKey(metadata *sopsMetadata, identities []age.Identity) ([]byte, error) {
+	for _, recipient := range metadata.Age {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(recipient.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+
+		return io.ReadAll(r)
+	}
+
+	return nil, errors.New("no age recipient matches the identities")
+}
+
+type sopsDecrypter struct {
+	key              []byte
+	hash             hash.Hash
+	macOnlyEncrypted bool
+}
+
+// decrypt walks the node at path like sops does: hash keys extend the path
+// while array elements share the path of the array
+func (d *sopsDecrypter) decrypt(node *yaml.Node, path []string) (dgo.Value, error) {
+	// sops adds encrypted comments to the MAC, in an order yaml.Node doesn't keep
+	for _, comment := range []string{node.HeadComment, node.LineComment, node.FootComment} {
+		if strings.Contains(comment, "ENC[AES256_GCM,") {
+			return nil, fmt.Errorf("encrypted comments are not supported, found one at %s", strings.Join(path, "."))
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		m := vf.MapWithCapacity(len(node.Content) / 2)
+		for i := 0; i < len(node.Content); i += 2 {
+			k := node.Content[i].Value
+			v, err := d.decrypt(node.Content[i+1], append(path[:len(path):len(path)], k))
+			if err != nil {
+				return nil, err
+			}
+			m.Put(k, v)
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		a := vf.MutableValues()
+		for _, e := range node.Content {
+			v, err := d.decrypt(e, path)
+			if err != nil {
+				return nil, err
+			}
+			a.Add(v)
+		}
+		return a, nil
+	case yaml.AliasNode:
+		return d.decrypt(node.Alias, path)
+	}
+
+	if node.Tag == "!!str" && sopsValue.MatchString(node.Value) {
+		plaintext, typ, err := d.decryptValue(node.Value, strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt %s: %s", strings.Join(path, "."), err)
+		}
+
+		d.hash.Write([]byte(plaintext))
+
+		v, err := sopsTypedValue(plaintext, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		return vf.Sensitive(v), nil
+	}
+
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if !d.macOnlyEncrypted {
+		b, err := sopsBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to hash %s: %s", strings.Join(path, "."), err)
+		}
+		d.hash.Write(b)
+	}
+
+	return vf.Value(v), nil
+}
+
+// decryptValue decrypts an ENC[AES256_GCM,...] value and returns its plaintext and type
+func (d *sopsDecrypter) decryptValue(value string, additionalData string) (string, string, error) {
+	m := sopsValue.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", errors.New("invalid encrypted value")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return "", "", err
+		}
+		parts[i] = b
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(d.key)
+	if err != nil {
+		return "", "", err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(plaintext), m[4], nil
+}
+
+func sopsTypedValue(plaintext string, typ string) (dgo.Value, error) {
+	switch typ {
+	case "str", "bytes":
+		return vf.String(plaintext), nil
+	case "int":
+		i, err := strconv.ParseInt(plaintext, 10, 64)
+		return vf.Integer(i), err
+	case "float":
+		f, err := strconv.ParseFloat(plaintext, 64)
+		return vf.Float(f), err
+	case "bool":
+		b, err := strconv.ParseBool(plaintext)
+		return vf.Boolean(b), err
+	}
+
+	return nil, fmt.Errorf("unsupported value type %s", typ)
+}
+
+// sopsBytes returns the representation of an unencrypted value that sops adds to the MAC
+func sopsBytes(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to bytes", v)
+}
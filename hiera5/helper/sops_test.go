@@ -0,0 +1,62 @@
+package helper
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func testSopsFixture(t *testing.T) ([]byte, []age.Identity) {
+	b, err := os.ReadFile("../test-fixtures/hieradata/secrets.sops.yaml")
+	if err != nil {
+		t.Fatalf("Error reading fixture: %s", err)
+	}
+
+	identities, err := readAgeIdentities("../test-fixtures/keys/age.txt")
+	if err != nil {
+		t.Fatalf("Error reading identities: %s", err)
+	}
+
+	return b, identities
+}
+
+func TestDecryptSops(t *testing.T) {
+	b, identities := testSopsFixture(t)
+
+	data, err := decryptSops(b, identities)
+	if err != nil {
+		t.Fatalf("Error decrypting: %s", err)
+	}
+
+	if data.Get("sops") != nil {
+		t.Errorf("sops metadata should not be part of the data")
+	}
+
+	if v, _ := unwrapSensitive(data.Get("sops_password")); v.String() != "s0ps" {
+		t.Errorf("sops_password is %s; want %s", v, "s0ps")
+	}
+}
+
+func TestDecryptSopsTampered(t *testing.T) {
+	b, identities := testSopsFixture(t)
+
+	tampered := strings.Replace(string(b), "sops_public_unencrypted: visible", "sops_public_unencrypted: tampered", 1)
+	if _, err := decryptSops([]byte(tampered), identities); err == nil || !strings.Contains(err.Error(), "MAC mismatch") {
+		t.Errorf("Error decrypting a tampered file should return a MAC mismatch: %v", err)
+	}
+}
+
+func TestDecryptSopsWrongIdentity(t *testing.T) {
+	b, _ := testSopsFixture(t)
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Error generating identity: %s", err)
+	}
+
+	if _, err = decryptSops(b, []age.Identity{identity}); err == nil {
+		t.Errorf("Error decrypting with the wrong identity should return an error")
+	}
+}
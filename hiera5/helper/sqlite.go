@@ -0,0 +1,130 @@
+package helper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+
+	// registers the sqlite3 driver, running an embedded WebAssembly build of SQLite
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// sqliteLookupKey is a lookup_key function that runs the query option against
+// the SQLite database given by the path option, read only. The query binds the
+// looked up key to :key and the entries of the parameters option, which usually
+// interpolate scope variables, to their names. The first column of the first row
+// is the value, text is decoded as YAML or JSON. No row, or NULL, is not found.
+func sqliteLookupKey(pc sdk.ProviderContext, key string) dgo.Value {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return nil
+	}
+
+	pv := pc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+
+	qv := pc.Option(`query`)
+	if qv == nil {
+		panic(api.MissingRequiredOption(`query`))
+	}
+
+	args := []interface{}{sql.Named("key", key)}
+	if params, ok := pc.Option(`parameters`).(dgo.Map); ok {
+		params.EachEntry(func(e dgo.MapEntry) {
+			args = append(args, sql.Named(e.Key().String(), e.Value().String()))
+		})
+	}
+
+	value, err := sqliteQuery(sqliteDB(sc, path), qv.String(), args...)
+	if err != nil {
+		panic(fmt.Errorf("could not query %s: %s", path, err.Error()))
+	}
+
+	return value
+}
+
+// sqliteDB returns the read only handle of the database at path, opened once
+// per session. The handle is closed with the session, see closeCached.
+func sqliteDB(sc api.ServerContext, path string) *sql.DB {
+	cache := sc.Invocation().TopProviderCache()
+	cacheKey := `sqlite::` + path
+
+	if v, ok := cache.Load(cacheKey); ok {
+		return v.(dgo.Native).GoValue().(*sql.DB)
+	}
+
+	db, err := sql.Open("sqlite3", sqliteURI(path))
+	if err != nil {
+		panic(fmt.Errorf("could not open %s: %s", path, err.Error()))
+	}
+
+	// Another lookup may have opened the database meanwhile
+	if v, loaded := cache.LoadOrStore(cacheKey, vf.Value(db)); loaded {
+		_ = db.Close()
+		return v.(dgo.Native).GoValue().(*sql.DB)
+	}
+
+	return db
+}
+
+// sqliteURI returns the read only URI of the database at path. SQLite decodes
+// percent escapes in the path of URIs, which keeps ? and # in path from
+// starting the query or the fragment.
+func sqliteURI(path string) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(filepath.ToSlash(path))
+
+	return "file:" + escaped + "?mode=ro"
+}
+
+// sqliteQuery returns the first column of the first row returned by query, or
+// nil when there is none
+func sqliteQuery(db *sql.DB, query string, args ...interface{}) (dgo.Value, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("the query returns no columns")
+	}
+
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+
+	if err = rows.Scan(values...); err != nil {
+		return nil, err
+	}
+
+	switch v := (*values[0].(*interface{})).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return decodeYAMLValue([]byte(v)), nil
+	case []byte:
+		return decodeYAMLValue(v), nil
+	default:
+		return vf.Value(v), nil
+	}
+}
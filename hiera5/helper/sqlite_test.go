@@ -0,0 +1,150 @@
+package helper
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+)
+
+// newSqliteConfig creates a database and a hiera config querying it in dir
+func newSqliteConfig(t *testing.T, dir string) string {
+	db, err := sql.Open("sqlite3", strings.TrimSuffix(sqliteURI(filepath.Join(dir, "inventory.db")), "ro")+"rwc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		`CREATE TABLE settings (host TEXT, key TEXT, value)`,
+		`INSERT INTO settings VALUES
+			('web01', 'role', 'web'),
+			('web01', 'ports', '[80, 443]'),
+			('web01', 'limits', 'cpu: 2' || char(10) || 'memory: 4Gi'),
+			('web01', 'weight', 10),
+			('web01', 'retired', NULL),
+			('web01', 'note', 'a: b: c'),
+			('db01', 'role', 'database')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := filepath.Join(dir, "hiera.yaml")
+	err = os.WriteFile(config, []byte(`version: 5
+hierarchy:
+  - name: Inventory
+    lookup_key: sqlite_lookup_key
+    datadir: .
+    path: inventory.db
+    options:
+      query: SELECT value FROM settings WHERE host = :host AND key = :key
+      parameters:
+        host: "%{hostname}"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestLookupSqlite(t *testing.T) {
+	config := newSqliteConfig(t, t.TempDir())
+
+	for _, tc := range []struct {
+		host string
+		key  string
+		want string
+	}{
+		{"web01", "role", `"web"`},
+		{"db01", "role", `"database"`},
+		{"web01", "ports", `[80,443]`},
+		{"web01", "limits", `{"cpu":2,"memory":"4Gi"}`},
+		{"web01", "weight", `10`},
+		{"web01", "note", `"a: b: c"`},
+		{"web01", "retired", ``},
+		{"web01", "doesnt_exists", ``},
+		{"db01'; DROP TABLE settings; --", "role", ``},
+	} {
+		r, err := Lookup(context.TODO(), config, "first", tc.key, "", map[string]interface{}{"hostname": tc.host})
+		out, _ := r.JSON()
+		sources := r.Sources
+		if err != nil {
+			t.Errorf("Error lookup %s on %s: %s", tc.key, tc.host, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != tc.want {
+			t.Errorf("%s on %s is %s; want %s", tc.key, tc.host, v, tc.want)
+		}
+
+		if tc.want != "" && (len(sources) != 1 || sources[0] != (Source{Level: "Inventory", Path: "inventory.db"})) {
+			t.Errorf("%s on %s sources are %v", tc.key, tc.host, sources)
+		}
+	}
+}
+
+func TestLookupSqliteInvalidQuery(t *testing.T) {
+	config := newSqliteConfig(t, t.TempDir())
+
+	b, _ := os.ReadFile(config)
+	if err := os.WriteFile(config, []byte(strings.Replace(string(b), "FROM settings", "FROM doesnt_exists", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Lookup(context.TODO(), config, "first", "role", "", map[string]interface{}{"hostname": "web01"})
+	if err == nil || !strings.Contains(err.Error(), "no such table") {
+		t.Errorf("Error lookup with an invalid query is %v; want no such table", err)
+	}
+}
+
+func TestLookupSqliteEscapedPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data?v=1#x%41")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newSqliteConfig(t, dir)
+
+	r, err := Lookup(context.TODO(), config, "first", "role", "", map[string]interface{}{"hostname": "web01"})
+	if out, _ := r.JSON(); err != nil || string(out) != `"web"` {
+		t.Errorf("Error lookup role: %s %s", out, err)
+	}
+}
+
+func TestLookupSqliteSession(t *testing.T) {
+	config := newSqliteConfig(t, t.TempDir())
+
+	s := &Sessions{}
+
+	var dbs []*sql.DB
+	for _, host := range []string{"web01", "db01"} {
+		if r, err := s.Lookup(context.TODO(), config, "first", "role", "", map[string]interface{}{"hostname": host}); err != nil || !r.Found {
+			t.Fatalf("Error lookup role on %s: %v %s", host, r.Value, err)
+		}
+
+		s.sessions[config].TopProviderCache().Range(func(_, v interface{}) bool {
+			if n, ok := v.(dgo.Native); ok {
+				if db, ok := n.GoValue().(*sql.DB); ok {
+					dbs = append(dbs, db)
+				}
+			}
+			return true
+		})
+	}
+
+	if len(dbs) != 2 || dbs[0] != dbs[1] {
+		t.Fatalf("Error lookups should share one database handle: %v", dbs)
+	}
+
+	s.Close()
+
+	if err := dbs[0].Ping(); err == nil {
+		t.Errorf("Error the database handle should be closed with the session")
+	}
+}
@@ -0,0 +1,79 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+// terraformState is the part of the Terraform state format holding the outputs
+// of the root module
+type terraformState struct {
+	Version int `json:"version"`
+	Outputs map[string]struct {
+		Value     json.RawMessage `json:"value"`
+		Sensitive bool            `json:"sensitive"`
+	} `json:"outputs"`
+}
+
+// terraformStateData is a data_hash function that returns the root module
+// outputs of the Terraform state file given by the path option, or of the
+// terraform.tfstate file within it when it's a directory. Sensitive outputs
+// are Sensitive.
+func terraformStateData(pc sdk.ProviderContext) dgo.Map {
+	pv := pc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, "terraform.tfstate")
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vf.Map()
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	data, err := terraformOutputs(b)
+	if err != nil {
+		panic(fmt.Errorf("could not read the outputs of %s: %s", path, err.Error()))
+	}
+
+	return data
+}
+
+// terraformOutputs returns the root module outputs of the state b
+func terraformOutputs(b []byte) (dgo.Map, error) {
+	var state terraformState
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+
+	if state.Version != 4 {
+		return nil, fmt.Errorf("unsupported state version %d", state.Version)
+	}
+
+	data := vf.MapWithCapacity(len(state.Outputs))
+	for name, output := range state.Outputs {
+		var value dgo.Value = streamer.UnmarshalJSON(output.Value, nil)
+		if output.Sensitive {
+			value = vf.Sensitive(value)
+		}
+		data.Put(name, value)
+	}
+
+	return data, nil
+}
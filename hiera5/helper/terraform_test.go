@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTerraformOutputs(t *testing.T) {
+	data, err := terraformOutputs([]byte(`{"version":4,"outputs":{"port":{"value":5432,"type":"number"}}}`))
+	if err != nil {
+		t.Errorf("Error reading outputs: %s", err)
+	}
+
+	if v := data.Get("port"); v == nil || v.String() != "5432" {
+		t.Errorf("port is %v; want 5432", v)
+	}
+
+	if _, err := terraformOutputs([]byte(`{"version":3,"modules":[]}`)); err == nil {
+		t.Errorf("Error a version 3 state should return an error")
+	}
+
+	if _, err := terraformOutputs([]byte(`{"version":4`)); err == nil {
+		t.Errorf("Error a truncated state should return an error")
+	}
+}
+
+func TestLookupTerraformStateDirectory(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "hiera.yaml")
+
+	files := map[string]string{
+		"hiera.yaml":                    "version: 5\nhierarchy:\n  - name: State\n    data_hash: terraform_state_data\n    path: states/%{environment}\n    datadir: .\n",
+		"states/live/terraform.tfstate": `{"version":4,"outputs":{"cluster":{"value":"live-1","type":"string"}}}`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := Lookup(context.TODO(), config, "first", "cluster", "", map[string]interface{}{"environment": "live"})
+	out, _ := r.JSON()
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if v := strings.TrimSpace(string(out)); v != `"live-1"` {
+		t.Errorf("cluster is %s; want %s", v, `"live-1"`)
+	}
+}
@@ -0,0 +1,39 @@
+package helper
+
+import "os"
+
+// tree returns the directory holding the files identified by digest, calling
+// extract to fill it the first time. The directory is private to s and removed
+// by Close, so that files are never read from a copy another process could
+// have altered and don't pile up between runs.
+func (s *Sessions) tree(kind string, digest string, extract func(dir string) error) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := kind + "/" + digest
+	if dir, ok := s.trees[id]; ok {
+		return dir, nil
+	}
+
+	if s.treesDir == "" {
+		dir, err := os.MkdirTemp("", "terraform-provider-hiera5-")
+		if err != nil {
+			return "", err
+		}
+		s.treesDir = dir
+		s.trees = make(map[string]string)
+	}
+
+	dir, err := os.MkdirTemp(s.treesDir, kind+"-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := extract(dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	s.trees[id] = dir
+
+	return dir, nil
+}
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+)
+
+// vaultLookupKey is a lookup_key function that maps key to a field of the
+// HashiCorp Vault KV v2 secret given by the mount and secret_path options, the
+// latter usually interpolating scope variables. The Vault address defaults to
+// VAULT_ADDR and the token is read from the file given by the token_file option,
+// or from VAULT_TOKEN. Secrets are read once per session. Found values are
+// Sensitive.
+func vaultLookupKey(pc sdk.ProviderContext, key string) dgo.Value {
+	sc, ok := pc.(api.ServerContext)
+	if !ok {
+		return nil
+	}
+
+	address := os.Getenv("VAULT_ADDR")
+	if av := sc.Option(`address`); av != nil {
+		address = av.String()
+	}
+
+	if address == "" {
+		panic(api.MissingRequiredOption(`address`))
+	}
+
+	mount := "secret"
+	if mv := sc.Option(`mount`); mv != nil {
+		mount = mv.String()
+	}
+
+	pv := sc.Option(`secret_path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`secret_path`))
+	}
+
+	secretURL := strings.TrimRight(address, "/") + "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.Trim(pv.String(), "/")
+
+	cacheKey := `vault::` + secretURL
+	secret, ok := sc.CachedValue(cacheKey)
+	if !ok {
+		secret = readVaultSecret(secretURL, vaultToken(sc))
+		sc.Cache(cacheKey, secret)
+	}
+
+	value := secret.(dgo.Map).Get(key)
+	if value == nil {
+		return nil
+	}
+
+	return vf.Sensitive(value)
+}
+
+// vaultToken returns the token read from the token_file option, or VAULT_TOKEN
+func vaultToken(sc api.ServerContext) string {
+	tv := sc.Option(`token_file`)
+	if tv == nil {
+		return os.Getenv("VAULT_TOKEN")
+	}
+
+	path := configRelative(sc, tv.String())
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// readVaultSecret returns the fields of the KV v2 secret at secretURL, or an
+// empty map when there is no such secret
+func readVaultSecret(secretURL string, token string) dgo.Map {
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+
+	req, err := http.NewRequest(http.MethodGet, secretURL, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		panic(fmt.Errorf("could not read vault secret %s: %s", redactURL(secretURL), err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return vf.Map()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		panic(fmt.Errorf("could not read vault secret %s: %s: %s", redactURL(secretURL), resp.Status, strings.TrimSpace(string(b))))
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		panic(fmt.Errorf("could not decode vault secret %s: %s", redactURL(secretURL), err.Error()))
+	}
+
+	return vf.Value(secret.Data.Data).(dgo.Map)
+}
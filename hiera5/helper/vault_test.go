@@ -0,0 +1,175 @@
+package helper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newVaultServer(t *testing.T, token string, requests *int32) *httptest.Server {
+	secrets := map[string]string{
+		"/v1/kv/data/apps/api/live": `{"data":{"data":{"db_password":"v4ult","db_pool":{"min":1,"max":8}},"metadata":{"version":3}}}`,
+		"/v1/kv/data/apps/common":   `{"data":{"data":{"db_password":"c0mmon","db_user":"app"},"metadata":{"version":1}}}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		secret, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(secret))
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_ADDR", srv.URL)
+
+	return srv
+}
+
+func TestLookupVault(t *testing.T) {
+	var requests int32
+	newVaultServer(t, "hvs.test-token", &requests)
+
+	for _, tc := range []struct {
+		key         string
+		environment string
+		want        string
+		level       string
+	}{
+		{"db_password", "live", `"v4ult"`, "Vault"},
+		{"db_pool", "live", `{"max":8,"min":1}`, "Vault"},
+		{"db_user", "live", `"app"`, "Vault Common"},
+		{"db_password", "staging", `"c0mmon"`, "Vault Common"},
+	} {
+		r, err := Lookup(
+			context.TODO(),
+			"../test-fixtures/hiera-vault.yaml",
+			"first",
+			tc.key,
+			"",
+			map[string]interface{}{"service": "api", "environment": tc.environment})
+		out, _ := r.JSON()
+		sources, sensitive := r.Sources, r.Sensitive
+		if err != nil {
+			t.Errorf("Error lookup %s: %s", tc.key, err)
+		}
+
+		if v := strings.TrimSpace(string(out)); v != tc.want {
+			t.Errorf("%s in %s is %s; want %s", tc.key, tc.environment, v, tc.want)
+		}
+
+		if !sensitive {
+			t.Errorf("%s in %s is not sensitive", tc.key, tc.environment)
+		}
+
+		if len(sources) != 1 || sources[0].Level != tc.level {
+			t.Errorf("%s in %s sources are %v; want level %s", tc.key, tc.environment, sources, tc.level)
+		}
+	}
+}
+
+func TestLookupVaultNonExistant(t *testing.T) {
+	var requests int32
+	newVaultServer(t, "hvs.test-token", &requests)
+
+	r, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera-vault.yaml",
+		"first",
+		"doesnt_exists",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live"})
+	out, _ := r.JSON()
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if len(out) != 0 {
+		t.Errorf("Error non existent key should return an empty value: %s", out)
+	}
+}
+
+func TestLookupVaultCache(t *testing.T) {
+	var requests int32
+	newVaultServer(t, "hvs.test-token", &requests)
+
+	_, err := LookupScopes(
+		context.TODO(),
+		"../test-fixtures/hiera-vault.yaml",
+		"first",
+		"db_user",
+		"",
+		map[string]map[string]interface{}{
+			"a": {"service": "api", "environment": "live"},
+			"b": {"service": "api", "environment": "live"},
+		},
+		false)
+	if err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("vault received %d requests; want 2", requests)
+	}
+}
+
+func TestLookupVaultSessionCache(t *testing.T) {
+	var requests int32
+	newVaultServer(t, "hvs.test-token", &requests)
+
+	s := &Sessions{}
+	defer s.Close()
+
+	// Secrets are read once for all the lookups of a provider run
+	for _, key := range []string{"db_password", "db_user", "db_password"} {
+		if _, err := s.Lookup(context.TODO(), "../test-fixtures/hiera-vault.yaml", "first", key, "", map[string]interface{}{"service": "api", "environment": "live"}); err != nil {
+			t.Errorf("Error lookup %s: %s", key, err)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("vault received %d requests; want 2", requests)
+	}
+
+	// and again by the next run
+	other := &Sessions{}
+	defer other.Close()
+
+	if _, err := other.Lookup(context.TODO(), "../test-fixtures/hiera-vault.yaml", "first", "db_password", "", map[string]interface{}{"service": "api", "environment": "live"}); err != nil {
+		t.Errorf("Error lookup: %s", err)
+	}
+
+	if requests == 2 {
+		t.Errorf("vault received no request from the next run")
+	}
+}
+
+func TestLookupVaultForbidden(t *testing.T) {
+	var requests int32
+	newVaultServer(t, "hvs.other-token", &requests)
+
+	_, err := Lookup(
+		context.TODO(),
+		"../test-fixtures/hiera-vault.yaml",
+		"first",
+		"db_password",
+		"",
+		map[string]interface{}{"service": "api", "environment": "live"})
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf("Error lookup with a denied token is %v; want 403 Forbidden", err)
+	}
+}
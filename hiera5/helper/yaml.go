@@ -0,0 +1,19 @@
+package helper
+
+import (
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAMLValue decodes b as YAML, a superset of JSON. Values that aren't
+// valid YAML are returned as strings.
+func decodeYAMLValue(b []byte) dgo.Value {
+	var v interface{}
+
+	if err := yaml.Unmarshal(b, &v); err != nil || v == nil {
+		return vf.String(string(b))
+	}
+
+	return vf.Value(v)
+}
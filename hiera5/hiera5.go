@@ -1,23 +1,44 @@
 package hiera5
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
 
-	"github.com/spf13/cast"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/lyraproj/dgo/dgo"
 
 	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
+// errNotFound is wrapped by the errors of lookups that found no value, the
+// only ones a default value stands in for
+var errNotFound = errors.New("not found")
+
 type override func(h *hiera5) *hiera5
 
 type hiera5 struct {
-	Config string
-	Scope  map[string]interface{}
-	Merge  string
+	Config        string
+	ConfigID      string
+	Scope         map[string]interface{}
+	Merge         string
+	StrictScope   bool
+	RequiredScope []string
+	Schemas       map[string]string
+	Schema        string
+	SensitiveKeys []string
+	Sessions      *helper.Sessions
+
+	sources   *[]helper.Source
+	sensitive *bool
 }
 
 func WithScopeOverride(scope map[string]interface{}) override {
@@ -26,11 +47,44 @@ func WithScopeOverride(scope map[string]interface{}) override {
 			return h
 		}
 
-		return &hiera5{
-			Config: h.Config,
-			Scope:  scope,
-			Merge:  h.Merge,
+		o := *h
+		o.Scope = scope
+
+		return &o
+	}
+}
+
+func WithSchema(schema string) override {
+	return func(h *hiera5) *hiera5 {
+		if schema == "" {
+			return h
 		}
+
+		o := *h
+		o.Schema = schema
+
+		return &o
+	}
+}
+
+// WithSources makes the lookup store the hierarchy levels and data files the value was found in into sources
+func WithSources(sources *[]helper.Source) override {
+	return func(h *hiera5) *hiera5 {
+		o := *h
+		o.sources = sources
+
+		return &o
+	}
+}
+
+// WithSensitive makes the lookup report into sensitive whether the value must be
+// kept out of plan output
+func WithSensitive(sensitive *bool) override {
+	return func(h *hiera5) *hiera5 {
+		o := *h
+		o.sensitive = sensitive
+
+		return &o
 	}
 }
 
@@ -51,102 +105,515 @@ func newHiera5(config string, scope map[string]interface{}, merge string) hiera5
 	}
 }
 
-func (h *hiera5) lookup(ctx context.Context, key string, valueType string) ([]byte, error) {
-	out, err := helper.Lookup(ctx, h.Config, h.Merge, key, valueType, h.Scope)
-	if err == nil && string(out) == "" {
-		return out, fmt.Errorf("key '%s' not found", key)
+// sessionLookup looks key up in the session of the config, with StrictScope
+// checking the scope within that same session first
+func (h *hiera5) sessionLookup(ctx context.Context, key string, valueType string) (helper.Result, error) {
+	if !h.StrictScope {
+		return h.Sessions.Lookup(ctx, h.Config, h.Merge, key, valueType, h.Scope)
 	}
 
-	if !json.Valid(out) {
-		return out, fmt.Errorf("key '%s''s lookup returned invalid JSON: '%s'", key, out)
+	results, err := h.Sessions.LookupScopes(ctx, h.Config, h.Merge, key, valueType, map[string]map[string]interface{}{"": h.Scope}, true)
+	return results[""], err
+}
+
+func (h *hiera5) checkRequiredScope() error {
+	for _, name := range h.RequiredScope {
+		if _, ok := h.Scope[name]; !ok {
+			return &helper.ScopeError{Variable: name}
+		}
 	}
 
-	return out, err
+	return nil
+}
+
+// id returns an identifier derived from key and the scope, merge strategy and
+// config it is looked up with, so that the same key looked up differently gets
+// a different id. The config is identified by ConfigID when set, Config being
+// then a copy that changes from run to run.
+func (h *hiera5) id(key string, opts ...override) string {
+	o := handleOverrides(h, opts...)
+
+	config := o.Config
+	if o.ConfigID != "" {
+		config = o.ConfigID
+	}
+
+	// encoding/json sorts map keys, which makes the scope canonical
+	b, _ := json.Marshal(map[string]interface{}{
+		"key":    key,
+		"scope":  o.Scope,
+		"merge":  o.Merge,
+		"config": config,
+	})
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *hiera5) sourceHash(sources []helper.Source) (string, error) {
+	if len(sources) == 0 {
+		return "", nil
+	}
+
+	return helper.SourceHash(h.Config, sources)
+}
+
+func (h *hiera5) lookup(ctx context.Context, key string, valueType string) (helper.Result, error) {
+	if err := h.checkRequiredScope(); err != nil {
+		return helper.Result{}, err
+	}
+
+	result, err := h.sessionLookup(ctx, key, valueType)
+	if h.sources != nil {
+		*h.sources = result.Sources
+	}
+
+	if h.sensitive != nil {
+		*h.sensitive = result.Sensitive || h.isSensitiveKey(key)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	if !result.Found {
+		return result, fmt.Errorf("key '%s' %w", key, errNotFound)
+	}
+
+	if schemas := h.schemas(key); len(schemas) > 0 {
+		out, err := result.JSON()
+		if err != nil {
+			return result, fmt.Errorf("key '%s''s value can't be encoded to JSON: %w", key, err)
+		}
+
+		for _, schema := range schemas {
+			if err := h.Sessions.Validate(key, schema, out); err != nil {
+				var schemaErr *helper.SchemaError
+				if errors.As(err, &schemaErr) {
+					schemaErr.Sources = result.Sources
+				}
+
+				return helper.Result{}, err
+			}
+		}
+	}
+
+	return result, nil
 }
 
-func (h *hiera5) array(ctx context.Context, key string, opts ...override) ([]interface{}, error) {
+// schemas returns the JSON Schemas the value of key must validate against:
+// those registered on the provider for a matching key pattern, in pattern
+// order, followed by the one attached to the data source
+func (h *hiera5) schemas(key string) []string {
 	var (
-		f interface{}
-		e []interface{}
+		patterns []string
+		schemas  []string
 	)
 
-	out, err := handleOverrides(h, opts...).lookup(ctx, key, "Array")
-	if err != nil {
-		return nil, err
+	for pattern := range h.Schemas {
+		if ok, _ := path.Match(pattern, key); ok {
+			patterns = append(patterns, pattern)
+		}
 	}
 
-	_ = json.Unmarshal(out, &f)
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		schemas = append(schemas, h.Schemas[pattern])
+	}
 
-	if _, ok := f.([]interface{}); ok {
-		for _, v := range f.([]interface{}) {
-			e = append(e, cast.ToString(v))
+	if h.Schema != "" {
+		schemas = append(schemas, h.Schema)
+	}
+
+	return schemas
+}
+
+// isSensitiveKey reports whether key matches one of the sensitive key patterns
+func (h *hiera5) isSensitiveKey(key string) bool {
+	for _, pattern := range h.SensitiveKeys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
 		}
-	} else {
-		return nil, fmt.Errorf("key '%s' does not return a valid array", key)
 	}
 
-	return e, nil
+	return false
 }
 
-func (h *hiera5) hash(ctx context.Context, key string, opts ...override) (map[string]interface{}, error) {
-	var f interface{}
+// matrix looks key up in every scope of scopes, each of which is layered on top
+// of the scope of h, and returns the values, see plain, and their JSON encoding
+// keyed like scopes. Scopes in which key isn't found are left out.
+func (h *hiera5) matrix(ctx context.Context, key string, scopes map[string]map[string]interface{}, opts ...override) (map[string]attr.Value, map[string]attr.Value, error) {
+	results, err := handleOverrides(h, opts...).lookupScopes(ctx, key, scopes)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	e := make(map[string]interface{})
+	values := make(map[string]attr.Value, len(results))
+	jsonValues := make(map[string]attr.Value, len(results))
+	for label, result := range results {
+		v, out, err := plainJSON(key, result)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	out, err := handleOverrides(h, opts...).lookup(ctx, key, "Hash")
+		values[label], jsonValues[label] = v, types.StringValue(out)
+	}
+
+	return values, jsonValues, nil
+}
+
+// lookupScopes is the multi scope counterpart of lookup, it returns the results
+// keyed like scopes, leaving out the scopes in which key isn't found, and raises
+// the sensitive flag when any of the values is sensitive
+func (h *hiera5) lookupScopes(ctx context.Context, key string, scopes map[string]map[string]interface{}) (map[string]helper.Result, error) {
+	layered := make(map[string]map[string]interface{}, len(scopes))
+	for label, scope := range scopes {
+		vars := make(map[string]interface{}, len(h.Scope)+len(scope))
+		for k, v := range h.Scope {
+			vars[k] = v
+		}
+		for k, v := range scope {
+			vars[k] = v
+		}
+
+		if err := WithScopeOverride(vars)(h).checkRequiredScope(); err != nil {
+			return nil, err
+		}
+
+		layered[label] = vars
+	}
+
+	results, err := h.Sessions.LookupScopes(ctx, h.Config, h.Merge, key, "", layered, h.StrictScope)
 	if err != nil {
 		return nil, err
 	}
 
-	_ = json.Unmarshal(out, &f)
+	found := make(map[string]helper.Result, len(results))
+	for label, result := range results {
+		if !result.Found {
+			continue
+		}
+
+		// Several lookups may report into the same flag, it is only ever raised
+		if h.sensitive != nil && (result.Sensitive || h.isSensitiveKey(key)) {
+			*h.sensitive = true
+		}
+
+		for _, schema := range h.schemas(key) {
+			v, err := result.JSON()
+			if err != nil {
+				return nil, fmt.Errorf("key '%s''s value in scope '%s' can't be encoded to JSON: %w", key, label, err)
+			}
+
+			if err := h.Sessions.Validate(key, schema, v); err != nil {
+				var schemaErr *helper.SchemaError
+				if errors.As(err, &schemaErr) {
+					schemaErr.Sources = result.Sources
+				}
 
-	if _, ok := f.(map[string]interface{}); ok {
-		for k, v := range f.(map[string]interface{}) {
-			e[k] = cast.ToString(v)
+				return nil, err
+			}
 		}
-	} else {
-		return nil, fmt.Errorf("key '%s' does not return a valid hash", key)
+
+		found[label] = result
 	}
 
-	return e, nil
+	return found, nil
+}
+
+// difference is a key whose value differs between two scopes
+type difference struct {
+	Key string
+	// Type is one of added, removed or changed
+	Type string
+	// From and To are the JSON encoded values, empty when the key isn't found
+	From        string
+	To          string
+	FromSources []helper.Source
+	ToSources   []helper.Source
 }
 
-func (h *hiera5) value(ctx context.Context, key string, opts ...override) (string, error) {
-	var f interface{}
+// diff compares the values of keys, and of the entries of the hash found under
+// namespace, between the scopes from and to, both layered on top of the scope
+// of h. Entries of namespace are reported as namespace.entry with the sources
+// of namespace itself. The differences are sorted by key.
+func (h *hiera5) diff(ctx context.Context, keys []string, namespace string, from map[string]interface{}, to map[string]interface{}, opts ...override) ([]difference, error) {
+	var diffs []difference
+
+	o := handleOverrides(h, opts...)
+	scopes := map[string]map[string]interface{}{"from": from, "to": to}
+
+	for _, key := range keys {
+		results, err := o.lookupScopes(ctx, key, scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		values, sources, err := scopeJSON(key, results)
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := compare(key, values["from"], values["to"]); ok {
+			d.FromSources, d.ToSources = sources["from"], sources["to"]
+			diffs = append(diffs, d)
+		}
+	}
 
-	out, err := handleOverrides(h, opts...).lookup(ctx, key, "")
+	if namespace != "" {
+		results, err := o.lookupScopes(ctx, namespace, scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		values, sources, err := scopeJSON(namespace, results)
+		if err != nil {
+			return nil, err
+		}
+
+		fromEntries, err := namespaceEntries(namespace, values["from"])
+		if err != nil {
+			return nil, err
+		}
+
+		toEntries, err := namespaceEntries(namespace, values["to"])
+		if err != nil {
+			return nil, err
+		}
+
+		for entry := range fromEntries {
+			if _, ok := toEntries[entry]; !ok {
+				toEntries[entry] = ""
+			}
+		}
+
+		for entry, v := range toEntries {
+			if d, ok := compare(namespace+"."+entry, fromEntries[entry], v); ok {
+				d.FromSources, d.ToSources = sources["from"], sources["to"]
+				diffs = append(diffs, d)
+			}
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+
+	return diffs, nil
+}
+
+// scopeJSON returns the JSON encoded values of key in results and the sources
+// they were found in, keyed like results
+func scopeJSON(key string, results map[string]helper.Result) (map[string]string, map[string][]helper.Source, error) {
+	values := make(map[string]string, len(results))
+	sources := make(map[string][]helper.Source, len(results))
+	for label, result := range results {
+		out, err := result.JSON()
+		if err != nil {
+			return nil, nil, fmt.Errorf("key '%s''s value in scope '%s' can't be encoded to JSON: %w", key, label, err)
+		}
+
+		values[label], sources[label] = string(out), result.Sources
+	}
+
+	return values, sources, nil
+}
+
+// compare returns the difference between the JSON encoded values from and to of
+// key, if any. Values are compared regardless of the order of their hash keys.
+func compare(key string, from string, to string) (difference, bool) {
+	d := difference{Key: key, From: from, To: to}
+
+	switch {
+	case from == "" && to == "":
+		return d, false
+	case from == "":
+		d.Type = "added"
+	case to == "":
+		d.Type = "removed"
+	case canonicalJSON(from) == canonicalJSON(to):
+		return d, false
+	default:
+		d.Type = "changed"
+	}
+
+	return d, true
+}
+
+// namespaceEntries returns the JSON encoded entries of the hash value of namespace
+func namespaceEntries(namespace string, value string) (map[string]string, error) {
+	var hash map[string]json.RawMessage
+
+	entries := map[string]string{}
+	if value == "" {
+		return entries, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &hash); err != nil {
+		return nil, fmt.Errorf("namespace '%s' is not a hash: %s", namespace, value)
+	}
+
+	for k, v := range hash {
+		entries[k] = string(v)
+	}
+
+	return entries, nil
+}
+
+// canonicalJSON re-encodes value with its hash keys sorted and its numbers in
+// their shortest form, so that e.g. 1, 1.0 and 1e0 compare equal without losing
+// the precision of large numbers
+func canonicalJSON(value string) string {
+	var v interface{}
+
+	d := json.NewDecoder(strings.NewReader(value))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return value
+	}
+
+	b, _ := json.Marshal(canonicalNumbers(v))
+
+	return string(b)
+}
+
+func canonicalNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _, err := big.ParseFloat(v.String(), 10, 512, big.ToNearestEven)
+		if err != nil {
+			return v
+		}
+		return json.Number(f.Text('g', -1))
+	case []interface{}:
+		for i, e := range v {
+			v[i] = canonicalNumbers(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = canonicalNumbers(e)
+		}
+	}
+
+	return v
+}
+
+// array returns the array value of key as a list of strings, for value, and
+// as a list of elementType, for typed_value
+func (h *hiera5) array(ctx context.Context, key string, elementType string, opts ...override) (types.List, types.Dynamic, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "Array")
+	if err != nil {
+		return types.ListNull(types.StringType), types.DynamicNull(), err
+	}
+
+	v, ok := result.Value.(dgo.Array)
+	if !ok {
+		return types.ListNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid array", key)
+	}
+
+	list, err := listValue(v)
+	if err != nil {
+		return types.ListNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid array: %w", key, err)
+	}
+
+	typed, err := arrayValue(v, elementType)
+	if err != nil {
+		return types.ListNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid array of %s: %w", key, elementType, err)
+	}
+
+	return list, typed, nil
+}
+
+// hash returns the hash value of key, flattened unless flatten is nil, as a
+// map of strings, for value, and as a map of elementType, for typed_value
+func (h *hiera5) hash(ctx context.Context, key string, elementType string, flatten *flattening, opts ...override) (types.Map, types.Dynamic, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "Hash")
+	if err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), err
+	}
+
+	v, ok := result.Value.(dgo.Map)
+	if !ok {
+		return types.MapNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid hash", key)
+	}
+
+	if v, err = flatten.hash(v); err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' can't be flattened: %w", key, err)
+	}
+
+	hash, err := mapValue(v)
 	if err != nil {
-		return "", err
+		return types.MapNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid hash: %w", key, err)
 	}
 
-	_ = json.Unmarshal(out, &f)
+	typed, err := hashValue(v, elementType)
+	if err != nil {
+		return types.MapNull(types.StringType), types.DynamicNull(), fmt.Errorf("key '%s' does not return a valid hash of %s: %w", key, elementType, err)
+	}
 
-	return cast.ToString(f), nil
+	return hash, typed, nil
 }
 
-func (h *hiera5) bool(ctx context.Context, key string, opts ...override) (bool, error) {
-	var f interface{}
+func (h *hiera5) value(ctx context.Context, key string, opts ...override) (types.String, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "")
+	if err != nil {
+		return types.StringNull(), err
+	}
+
+	return stringValue(result.Value), nil
+}
+
+func (h *hiera5) bool(ctx context.Context, key string, opts ...override) (types.Bool, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "")
+	if err != nil {
+		return types.BoolNull(), err
+	}
+
+	return boolValue(result.Value), nil
+}
 
-	out, err := handleOverrides(h, opts...).lookup(ctx, key, "")
+func (h *hiera5) number(ctx context.Context, key string, opts ...override) (types.Number, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "Number")
 	if err != nil {
-		return false, err
+		return types.NumberNull(), err
 	}
 
-	_ = json.Unmarshal(out, &f)
+	v, ok := numberValue(result.Value)
+	if !ok {
+		return types.NumberNull(), fmt.Errorf("key '%s' does not return a valid number", key)
+	}
 
-	return cast.ToBool(f), nil
+	return v, nil
 }
 
 func (h *hiera5) json(ctx context.Context, key string, opts ...override) (string, error) {
-	var b bytes.Buffer
+	_, out, err := h.plain(ctx, key, opts...)
+
+	return out, err
+}
 
-	out, err := handleOverrides(h, opts...).lookup(ctx, key, "")
+// plain returns the value of key as a string, see plainJSON, together with
+// its JSON encoding
+func (h *hiera5) plain(ctx context.Context, key string, opts ...override) (types.String, string, error) {
+	result, err := handleOverrides(h, opts...).lookup(ctx, key, "")
 	if err != nil {
-		return "", err
+		return types.StringNull(), "", err
 	}
 
-	_ = json.Compact(&b, out)
-	out, _ = io.ReadAll(io.Reader(&b))
+	return plainJSON(key, result)
+}
+
+// plainJSON returns the value of result as a string, scalars as is and arrays
+// and hashes JSON encoded, together with its JSON encoding
+func plainJSON(key string, result helper.Result) (types.String, string, error) {
+	out, err := result.JSON()
+	if err != nil {
+		return types.StringNull(), "", fmt.Errorf("key '%s''s value can't be encoded to JSON: %w", key, err)
+	}
+
+	if s, ok := scalarString(result.Value); ok {
+		return types.StringValue(s), string(out), nil
+	}
 
-	return string(out), nil
+	return types.StringValue(string(out)), string(out), nil
 }
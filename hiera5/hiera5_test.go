@@ -2,44 +2,56 @@ package hiera5
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/spf13/cast"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
 const keyUnavailable = "doesnt_exists"
 
 func TestHiera5Lookup(t *testing.T) {
-	var f interface{}
-
 	hiera := testHiera5Config()
 
-	out, err := hiera.lookup(context.TODO(), "aws_cloudwatch_enable", "")
+	result, err := hiera.lookup(context.TODO(), "aws_cloudwatch_enable", "")
 	if err != nil {
 		t.Errorf("Error running hiera: %s", err)
 	}
 
-	err = json.Unmarshal(out, &f)
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %s", err)
+	if v, _ := scalarString(result.Value); !result.Found || v != "true" {
+		t.Errorf("aws_cloudwatch_enable is %s; want %s", v, "true")
 	}
 
-	v := cast.ToString(f)
-
-	if v != "true" {
-		t.Errorf("aws_cloudwatch_enable is %s; want %s", v, "true")
+	result2, err2 := hiera.lookup(context.TODO(), keyUnavailable, "")
+	if err2 == nil || result2.Found {
+		t.Errorf("Error running hiera on %s: %v", keyUnavailable, result2.Value)
 	}
 }
 
 func TestHiera5Array(t *testing.T) {
 	hiera := testHiera5Config()
 
-	v, err := hiera.array(context.TODO(), "java_opts")
+	list, _, err := hiera.array(context.TODO(), "java_opts", "string")
 	if err != nil {
 		t.Errorf("Error running hiera.Array: %s", err)
 	}
 
+	var v []string
+	_ = list.ElementsAs(context.TODO(), &v, false)
+
 	if v[0] != "-Xms512m" {
 		t.Errorf(
 			"v[0] is %s; want %s",
@@ -64,20 +76,20 @@ func TestHiera5Array(t *testing.T) {
 		)
 	}
 
-	v2, err2 := hiera.array(context.TODO(), keyUnavailable)
-	if err2 == nil || v2 != nil {
+	v2, _, err2 := hiera.array(context.TODO(), keyUnavailable, "string")
+	if err2 == nil || !v2.IsNull() {
 		t.Errorf("Error running hiera.Array: %s", v2)
 	}
 
-	v3, err3 := hiera.array(context.TODO(), "aws_tags")
-	if err3 == nil || v3 != nil {
+	v3, _, err3 := hiera.array(context.TODO(), "aws_tags", "string")
+	if err3 == nil || !v3.IsNull() {
 		t.Errorf("Error running hiera.Array: %s", v3)
 	}
 
 	hieraBad := testHiera5ConfigBad()
 
-	v4, err4 := hieraBad.array(context.TODO(), "java_opts")
-	if err4 == nil || v4 != nil {
+	v4, _, err4 := hieraBad.array(context.TODO(), "java_opts", "string")
+	if err4 == nil || !v4.IsNull() {
 		t.Errorf("Error running hiera.Array: %s", v4)
 	}
 }
@@ -85,11 +97,14 @@ func TestHiera5Array(t *testing.T) {
 func TestHiera5Hash(t *testing.T) {
 	hiera := testHiera5Config()
 
-	v, err := hiera.hash(context.TODO(), "aws_tags")
+	hash, _, err := hiera.hash(context.TODO(), "aws_tags", "string", nil)
 	if err != nil {
 		t.Errorf("Error running hiera.Hash: %s", err)
 	}
 
+	var v map[string]string
+	_ = hash.ElementsAs(context.TODO(), &v, false)
+
 	if v["team"] != "A" {
 		t.Errorf("aws_tags.team is %s; want %s", v, "A")
 	}
@@ -98,24 +113,167 @@ func TestHiera5Hash(t *testing.T) {
 		t.Errorf("aws_tags.tier is %s; want %s", v, "1")
 	}
 
-	v2, err2 := hiera.hash(context.TODO(), keyUnavailable)
-	if err2 == nil || v2 != nil {
+	v2, _, err2 := hiera.hash(context.TODO(), keyUnavailable, "string", nil)
+	if err2 == nil || !v2.IsNull() {
 		t.Errorf("Error running hiera.Hash: %s", v2)
 	}
 
-	v3, err3 := hiera.hash(context.TODO(), "java_opts")
-	if err3 == nil || v3 != nil {
+	v3, _, err3 := hiera.hash(context.TODO(), "java_opts", "string", nil)
+	if err3 == nil || !v3.IsNull() {
 		t.Errorf("Error running hiera.Hash: %s", v3)
 	}
 
 	hieraBad := testHiera5ConfigBad()
 
-	v4, err4 := hieraBad.hash(context.TODO(), "aws_tags")
-	if err4 == nil || v4 != nil {
+	v4, _, err4 := hieraBad.hash(context.TODO(), "aws_tags", "string", nil)
+	if err4 == nil || !v4.IsNull() {
 		t.Errorf("Error running hiera.Hash: %s", v4)
 	}
 }
 
+func TestHiera5ElementType(t *testing.T) {
+	hiera := testHiera5Config()
+
+	_, ports, err := hiera.array(context.TODO(), "ports", "number")
+	if err != nil {
+		t.Errorf("Error running hiera.array: %s", err)
+	}
+	if want := types.ListValueMust(types.NumberType, []attr.Value{
+		types.NumberValue(big.NewFloat(80)), types.NumberValue(big.NewFloat(443)),
+	}); !ports.UnderlyingValue().Equal(want) {
+		t.Errorf("ports is %s; want %s", ports, want)
+	}
+
+	_, users, err := hiera.array(context.TODO(), "users", "dynamic")
+	if err != nil {
+		t.Errorf("Error running hiera.array: %s", err)
+	}
+	tuple, ok := users.UnderlyingValue().(types.Tuple)
+	if !ok || len(tuple.Elements()) != 2 {
+		t.Fatalf("users is %s; want a tuple of 2 objects", users)
+	}
+	bob := tuple.Elements()[1].(types.Object).Attributes()
+	if !bob["name"].Equal(types.StringValue("bob")) ||
+		!bob["uid"].Equal(types.NumberValue(big.NewFloat(1002))) ||
+		!bob["groups"].Equal(types.TupleValueMust([]attr.Type{types.StringType}, []attr.Value{types.StringValue("deploy")})) {
+		t.Errorf("users[1] is %s", tuple.Elements()[1])
+	}
+
+	_, tags, err := hiera.hash(context.TODO(), "aws_tags", "dynamic", nil)
+	if err != nil {
+		t.Errorf("Error running hiera.hash: %s", err)
+	}
+	if want := types.ObjectValueMust(map[string]attr.Type{"team": types.StringType, "tier": types.NumberType}, map[string]attr.Value{
+		"team": types.StringValue("A"), "tier": types.NumberValue(big.NewFloat(1)),
+	}); !tags.UnderlyingValue().Equal(want) {
+		t.Errorf("aws_tags is %s; want %s", tags, want)
+	}
+
+	if _, v, err := hiera.hash(context.TODO(), "aws_tags", "number", nil); err == nil || !v.IsNull() {
+		t.Errorf("Error running hiera.hash: %s", v)
+	}
+
+	for _, tc := range []struct {
+		key         string
+		elementType string
+	}{
+		{"users", "string"},
+		{"ports", "bool"},
+		{"java_opts", "number"},
+	} {
+		if _, _, err := hiera.array(context.TODO(), tc.key, tc.elementType); err == nil {
+			t.Errorf("hiera.array(%s, %s) should fail", tc.key, tc.elementType)
+		}
+	}
+
+	// Nested values are refused as typed strings rather than turned into empty strings
+	if _, _, err := hiera.array(context.TODO(), "users", "string"); err == nil || !strings.Contains(err.Error(), `element_type = "dynamic"`) {
+		t.Errorf("Error running hiera.array on users as strings is %v; want a hint at dynamic", err)
+	}
+
+	// while the list of strings of value keeps them JSON encoded
+	list, _, err := hiera.array(context.TODO(), "users", "dynamic")
+	if err != nil {
+		t.Errorf("Error running hiera.array: %s", err)
+	}
+	var v []string
+	_ = list.ElementsAs(context.TODO(), &v, false)
+	if len(v) != 2 || !strings.Contains(v[1], `"name":"bob"`) {
+		t.Errorf("users as strings is %v; want JSON encoded users", v)
+	}
+}
+
+func TestHiera5Flatten(t *testing.T) {
+	hiera := testHiera5Config()
+
+	for _, tc := range []struct {
+		flatten *flattening
+		want    map[string]string
+	}{
+		{&flattening{separator: "."}, map[string]string{
+			"name": "api", "env.LOG_LEVEL": "info", "listeners.0.port": "80", "listeners.1.port": "443",
+		}},
+		{&flattening{separator: "_", brackets: true}, map[string]string{
+			"name": "api", "env_LOG_LEVEL": "info", "listeners[0]_port": "80", "listeners[1]_port": "443",
+		}},
+	} {
+		hash, _, err := hiera.hash(context.TODO(), "service", "string", tc.flatten)
+		if err != nil {
+			t.Errorf("Error running hiera.hash: %s", err)
+		}
+
+		var v map[string]string
+		_ = hash.ElementsAs(context.TODO(), &v, false)
+
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("service flattened with %+v is %v; want %v", *tc.flatten, v, tc.want)
+		}
+	}
+
+	if _, err := (&flattening{separator: "."}).hash(vf.Map("a.b", 1, "a", vf.Map("b", 2))); err == nil {
+		t.Errorf("Error flattening a.b twice should fail")
+	}
+}
+
+func TestDgoValue(t *testing.T) {
+	v := types.DynamicValue(types.TupleValueMust(
+		[]attr.Type{types.ObjectType{AttrTypes: map[string]attr.Type{"name": types.StringType, "uid": types.NumberType}}, types.BoolType},
+		[]attr.Value{
+			types.ObjectValueMust(map[string]attr.Type{"name": types.StringType, "uid": types.NumberType}, map[string]attr.Value{
+				"name": types.StringValue("alice"),
+				"uid":  types.NumberValue(big.NewFloat(1001)),
+			}),
+			types.BoolValue(true),
+		}))
+
+	want := vf.Values(vf.Map("name", "alice", "uid", 1001), true)
+	if got := dgoValue(v); !got.Equals(want) {
+		t.Errorf("dgoValue is %s; want %s", got, want)
+	}
+
+	if got := dgoValue(types.DynamicNull()); got != vf.Nil {
+		t.Errorf("dgoValue of null is %s; want nil", got)
+	}
+
+	// Numbers a float64 can't hold keep their precision
+	for _, want := range []string{"0.1", "9223372036854775808", "1.00000000000000000001"} {
+		f, _, _ := big.ParseFloat(want, 10, 512, big.ToNearestEven)
+
+		n, ok := numberValue(dgoValue(types.NumberValue(f)))
+		if !ok || n.ValueBigFloat().Cmp(f) != 0 {
+			t.Errorf("dgoValue of %s is the number %s; want %s", want, n, want)
+		}
+
+		if s := stringValue(dgoValue(types.NumberValue(f))); s.ValueString() != want {
+			t.Errorf("dgoValue of %s is the string %s; want %s", want, s, want)
+		}
+
+		if d := dynamicValue(dgoValue(types.NumberValue(f))); !d.Equal(types.NumberValue(f)) {
+			t.Errorf("dgoValue of %s is the dynamic value %s; want %s", want, d, want)
+		}
+	}
+}
+
 func TestHiera5Value(t *testing.T) {
 	hiera := testHiera5Config()
 
@@ -124,23 +282,117 @@ func TestHiera5Value(t *testing.T) {
 		t.Errorf("Error running hiera.Value: %s", err)
 	}
 
-	if v != "true" {
+	if v.ValueString() != "true" {
 		t.Errorf("aws_cloudwatch_enable is %s; want %s", v, "true")
 	}
 
 	v2, err2 := hiera.value(context.TODO(), keyUnavailable)
-	if err2 == nil || v2 != "" {
+	if err2 == nil || !v2.IsNull() {
 		t.Errorf("Error running hiera.value: %s", v2)
 	}
 
 	hieraBad := testHiera5ConfigBad()
 
 	v4, err4 := hieraBad.value(context.TODO(), "aws_cloudwatch_enable")
-	if err4 == nil || v4 != "" {
+	if err4 == nil || !v4.IsNull() {
 		t.Errorf("Error running hiera.value: %s", v4)
 	}
 }
 
+func TestProcessLookupError(t *testing.T) {
+	hiera := testHiera5Config()
+	broken := newHiera5("test-fixtures/hiera-broken.yaml", map[string]interface{}{}, "first")
+
+	_, notFound := hiera.value(context.TODO(), keyUnavailable)
+	if !errors.Is(notFound, errNotFound) || processLookupError(notFound, path.Root("key")) != nil {
+		t.Errorf("Error a missing key should leave room for the default: %v", notFound)
+	}
+
+	_, backendErr := broken.value(context.TODO(), "replicas")
+	_, conversionErr := hiera.number(context.TODO(), "aws_instance_size")
+
+	for _, err := range []error{backendErr, conversionErr} {
+		if err == nil || errors.Is(err, errNotFound) {
+			t.Errorf("Error %v should not be a missing key", err)
+		}
+
+		if diags := processLookupError(err, path.Root("key")); len(diags) != 1 || diags[0].Summary() != "lookup failed" {
+			t.Errorf("Error %v should be reported rather than masked by the default: %v", err, diags)
+		}
+	}
+}
+
+func TestProcessDefaultArray(t *testing.T) {
+	names := types.ListValueMust(types.StringType, []attr.Value{types.StringValue("a")})
+	users := types.DynamicValue(types.TupleValueMust(
+		[]attr.Type{types.ObjectType{AttrTypes: map[string]attr.Type{"uid": types.NumberType, "name": types.StringType}}},
+		[]attr.Value{types.ObjectValueMust(
+			map[string]attr.Type{"uid": types.NumberType, "name": types.StringType},
+			map[string]attr.Value{"uid": types.NumberValue(big.NewFloat(0)), "name": types.StringValue("root")},
+		)},
+	))
+
+	// value keeps the list of strings of default, typed_value converts it
+	value, p, diags := processDefault(names, types.DynamicNull())
+	list, typed, d := processDefaultArray(value, p, "dynamic")
+	if diags != nil || d != nil || !list.Equal(names) || !typed.UnderlyingValue().Equal(types.TupleValueMust([]attr.Type{types.StringType}, []attr.Value{types.StringValue("a")})) {
+		t.Errorf("default is %s and %s: %v %v", list, typed, diags, d)
+	}
+
+	// value JSON encodes the nested values of typed_default
+	value, p, diags = processDefault(types.ListNull(types.StringType), users)
+	list, _, d = processDefaultArray(value, p, "dynamic")
+	if want := types.ListValueMust(types.StringType, []attr.Value{types.StringValue(`{"name":"root","uid":0}`)}); diags != nil || d != nil || !list.Equal(want) {
+		t.Errorf("typed_default is %s; want %s: %v %v", list, want, diags, d)
+	}
+
+	if _, p, diags := processDefault(names, users); len(diags) != 1 || !p.Equal(path.Root("typed_default")) {
+		t.Errorf("Error default and typed_default should conflict: %v", diags)
+	}
+}
+
+func TestHiera5Number(t *testing.T) {
+	hiera := testHiera5Config()
+
+	for key, want := range map[string]string{"max_connections": "500", "cpu_ratio": "0.5"} {
+		v, err := hiera.number(context.TODO(), key)
+		if err != nil || v.ValueBigFloat().Text('f', -1) != want {
+			t.Errorf("%s is %s, %v; want %s", key, v, err, want)
+		}
+	}
+
+	v2, err2 := hiera.number(context.TODO(), "aws_instance_size")
+	if err2 == nil || !v2.IsNull() {
+		t.Errorf("Error running hiera.number on aws_instance_size: %s", v2)
+	}
+
+	v3, err3 := hiera.number(context.TODO(), keyUnavailable)
+	if err3 == nil || !v3.IsNull() {
+		t.Errorf("Error running hiera.number: %s", v3)
+	}
+}
+
+func TestCheckNumber(t *testing.T) {
+	for _, tc := range []struct {
+		value       float64
+		integerOnly bool
+		min         *big.Float
+		max         *big.Float
+		valid       bool
+	}{
+		{500, true, big.NewFloat(1), big.NewFloat(1000), true},
+		{0.5, false, nil, nil, true},
+		{0.5, true, nil, nil, false},
+		{0, false, big.NewFloat(1), nil, false},
+		{1001, false, nil, big.NewFloat(1000), false},
+		{1000, false, big.NewFloat(1000), big.NewFloat(1000), true},
+	} {
+		if err := checkNumber(big.NewFloat(tc.value), tc.integerOnly, tc.min, tc.max); (err == nil) != tc.valid {
+			t.Errorf("checkNumber(%v, %t, %v, %v) is %v; want valid %t", tc.value, tc.integerOnly, tc.min, tc.max, err, tc.valid)
+		}
+	}
+}
+
 func TestHiera5Json(t *testing.T) {
 	hiera := testHiera5Config()
 
@@ -166,6 +418,439 @@ func TestHiera5Json(t *testing.T) {
 	}
 }
 
+func TestHiera5StrictScope(t *testing.T) {
+	var scopeErr *helper.ScopeError
+
+	hiera := testHiera5Config()
+	hiera.StrictScope = true
+
+	v, err := hiera.value(context.TODO(), "aws_instance_size")
+	if err != nil {
+		t.Errorf("Error running hiera.value: %s", err)
+	}
+
+	if v.ValueString() != "t2.large" {
+		t.Errorf("aws_instance_size is %s; want %s", v, "t2.large")
+	}
+
+	v2, err2 := hiera.value(context.TODO(), "aws_instance_size", WithScopeOverride(map[string]interface{}{"service": "api"}))
+	if !errors.As(err2, &scopeErr) || !v2.IsNull() {
+		t.Errorf("Error running hiera.value with undefined environment: %s", err2)
+	}
+
+	hiera.StrictScope = false
+	hiera.RequiredScope = []string{"service", "environment"}
+
+	v3, err3 := hiera.value(context.TODO(), "aws_instance_size", WithScopeOverride(map[string]interface{}{"environment": "live"}))
+	if !errors.As(err3, &scopeErr) || scopeErr.Variable != "service" || !v3.IsNull() {
+		t.Errorf("Error running hiera.value with undefined service: %s", err3)
+	}
+}
+
+func TestHiera5StrictScopeSession(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "hiera.yaml")
+	for path, content := range map[string]string{
+		config:                                 "version: 5\nhierarchy:\n  - name: Service\n    path: \"%{service}.yaml\"\n",
+		filepath.Join(dir, "data", "api.yaml"): "replicas: 3\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hiera := newHiera5(config, map[string]interface{}{"service": "api"}, "first")
+	hiera.StrictScope = true
+	hiera.Sessions = &helper.Sessions{}
+	defer hiera.Sessions.Close()
+
+	for i := 0; i < 2; i++ {
+		v, err := hiera.value(context.TODO(), "replicas")
+		if err != nil || v.ValueString() != "3" {
+			t.Errorf("replicas is %s; want 3: %v", v, err)
+		}
+
+		// The scope is checked against the config of the session, read once,
+		// rather than against the file
+		if err := os.WriteFile(config, []byte("version: [5\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var scopeErr *helper.ScopeError
+	if _, err := hiera.value(context.TODO(), "replicas", WithScopeOverride(map[string]interface{}{})); !errors.As(err, &scopeErr) {
+		t.Errorf("Error running hiera.value with undefined service: %v", err)
+	}
+}
+
+func TestHiera5Schema(t *testing.T) {
+	var schemaErr *helper.SchemaError
+
+	hiera := testHiera5Config()
+	hiera.Schemas = map[string]string{"aws_*": "test-fixtures/schemas/aws_tags.json"}
+
+	v, err := hiera.json(context.TODO(), "aws_tags")
+	if err != nil {
+		t.Errorf("Error running hiera.json: %s", err)
+	}
+
+	if v != `{"team":"A","tier":1}` {
+		t.Errorf("aws_tags is %s; want %s", v, `{"team":"A","tier":1}`)
+	}
+
+	v2, err2 := hiera.json(context.TODO(), "aws_tags", WithSchema(`{"properties":{"tier":{"type":"string"}}}`))
+	if !errors.As(err2, &schemaErr) || v2 != "" {
+		t.Errorf("Error running hiera.json with schema override: %s", err2)
+	}
+
+	v3, err3 := hiera.json(context.TODO(), "aws_instance_size")
+	if !errors.As(err3, &schemaErr) || v3 != "" {
+		t.Errorf("Error running hiera.json on aws_instance_size: %s", err3)
+	}
+}
+
+func TestHiera5Sources(t *testing.T) {
+	var sources []helper.Source
+
+	hiera := testHiera5Config()
+	hiera.Merge = "first"
+
+	_, err := hiera.value(context.TODO(), "aws_cloudwatch_enable", WithSources(&sources))
+	if err != nil {
+		t.Errorf("Error running hiera.value: %s", err)
+	}
+
+	if len(sources) != 1 || sources[0].Level != "Environment" || sources[0].Path != "hieradata/environment/live.yaml" {
+		t.Errorf("sources are %v; want %v", sources, []helper.Source{{Level: "Environment", Path: "hieradata/environment/live.yaml"}})
+	}
+}
+
+func TestHiera5ID(t *testing.T) {
+	hiera := testHiera5Config()
+
+	id := hiera.id("aws_tags")
+	if id != hiera.id("aws_tags") {
+		t.Errorf("id is not stable: %s != %s", id, hiera.id("aws_tags"))
+	}
+
+	if id == hiera.id("java_opts") {
+		t.Errorf("id of different keys are equal: %s", id)
+	}
+
+	if id == hiera.id("aws_tags", WithScopeOverride(map[string]interface{}{"service": "api", "environment": "stage"})) {
+		t.Errorf("id of different scopes are equal: %s", id)
+	}
+
+	// Copies of the same git revision or bundle keep the ids of their keys
+	copies := []hiera5{hiera, hiera}
+	copies[0].Config, copies[0].ConfigID = "/tmp/a/hiera.yaml", "hiera.yaml@git:1234"
+	copies[1].Config, copies[1].ConfigID = "/tmp/b/hiera.yaml", "hiera.yaml@git:1234"
+	if a, b := copies[0].id("aws_tags"), copies[1].id("aws_tags"); a != b {
+		t.Errorf("id of copies of the same config differ: %s != %s", a, b)
+	}
+}
+
+func TestHiera5SourceHash(t *testing.T) {
+	var sources []helper.Source
+
+	hiera := testHiera5Config()
+
+	_, _, err := hiera.hash(context.TODO(), "aws_tags", "string", nil, WithSources(&sources))
+	if err != nil {
+		t.Errorf("Error running hiera.hash: %s", err)
+	}
+
+	h, err := hiera.sourceHash(sources)
+	if err != nil || len(h) != 64 {
+		t.Errorf("Error running hiera.sourceHash: %s %s", h, err)
+	}
+
+	h2, err2 := hiera.sourceHash(nil)
+	if err2 != nil || h2 != "" {
+		t.Errorf("Error running hiera.sourceHash without sources: %s %s", h2, err2)
+	}
+}
+
+// newStateDirectoryConfig returns a config whose terraform_state_data level
+// reads a directory of states, as in TestLookupTerraformStateDirectory, and
+// the state file of the live environment
+func newStateDirectoryConfig(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"hiera.yaml":                    "version: 5\nhierarchy:\n  - name: State\n    data_hash: terraform_state_data\n    path: states/%{environment}\n    datadir: .\n",
+		"states/live/terraform.tfstate": `{"version":4,"outputs":{"cluster":{"value":"live-1","type":"string"}}}`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return filepath.Join(dir, "hiera.yaml"), filepath.Join(dir, "states", "live", "terraform.tfstate")
+}
+
+func TestHiera5SourceHashDirectory(t *testing.T) {
+	config, state := newStateDirectoryConfig(t)
+	hiera := newHiera5(config, map[string]interface{}{"environment": "live"}, "first")
+
+	hash := func() string {
+		var sources []helper.Source
+
+		v, err := hiera.value(context.TODO(), "cluster", WithSources(&sources))
+		if err != nil || v.ValueString() != "live-1" {
+			t.Fatalf("Error running hiera.value: %s %v", v, err)
+		}
+
+		h, err := hiera.sourceHash(sources)
+		if err != nil || len(h) != 64 {
+			t.Fatalf("Error running hiera.sourceHash on %v: %s %v", sources, h, err)
+		}
+		return h
+	}
+
+	h1 := hash()
+	if err := os.WriteFile(state, []byte(`{"version":4,"outputs":{"cluster":{"value":"live-1","type":"string"},"region":{"value":"eu","type":"string"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if h2 := hash(); h2 == h1 {
+		t.Errorf("Error the hash of a changed state directory is unchanged: %s", h2)
+	}
+}
+
+func TestHiera5Matrix(t *testing.T) {
+	var scopeErr *helper.ScopeError
+
+	hiera := testHiera5Config()
+
+	v, j, err := hiera.matrix(context.TODO(), "aws_instance_size", map[string]map[string]interface{}{
+		"api":    {"service": "api"},
+		"worker": {"service": "worker"},
+	})
+	if err != nil {
+		t.Errorf("Error running hiera.matrix: %s", err)
+	}
+
+	if len(v) != 2 || !v["api"].Equal(types.StringValue("t2.large")) || !v["worker"].Equal(types.StringValue("t2.micro")) {
+		t.Errorf("aws_instance_size is %v; want %v", v, map[string]string{"api": "t2.large", "worker": "t2.micro"})
+	}
+
+	if !j["api"].Equal(types.StringValue(`"t2.large"`)) {
+		t.Errorf("aws_instance_size in api is JSON encoded as %v; want %s", j["api"], `"t2.large"`)
+	}
+
+	v2, _, err2 := hiera.matrix(context.TODO(), "enable_spot_instances", map[string]map[string]interface{}{
+		"stage": {"environment": "stage"},
+	}, WithScopeOverride(map[string]interface{}{"service": "worker"}))
+	if err2 != nil || !v2["stage"].Equal(types.StringValue("false")) {
+		t.Errorf("Error running hiera.matrix with scope override: %v %s", v2, err2)
+	}
+
+	v3, _, err3 := hiera.matrix(context.TODO(), keyUnavailable, map[string]map[string]interface{}{"api": {}})
+	if err3 != nil || len(v3) != 0 {
+		t.Errorf("Error running hiera.matrix: %v %s", v3, err3)
+	}
+
+	hiera.StrictScope = true
+
+	v4, _, err4 := hiera.matrix(context.TODO(), "aws_instance_size", map[string]map[string]interface{}{
+		"api": {},
+	}, WithScopeOverride(map[string]interface{}{"service": "api"}))
+	if !errors.As(err4, &scopeErr) || v4 != nil {
+		t.Errorf("Error running hiera.matrix with undefined environment: %s", err4)
+	}
+}
+
+func TestHiera5Diff(t *testing.T) {
+	hiera := testHiera5Config()
+
+	live := map[string]interface{}{"environment": "live"}
+	stage := map[string]interface{}{"environment": "stage"}
+
+	diffs, err := hiera.diff(context.TODO(), []string{"aws_instance_size", "aws_cloudwatch_enable", keyUnavailable}, "aws_tags", live, stage)
+	if err != nil {
+		t.Errorf("Error running hiera.diff: %s", err)
+	}
+
+	if len(diffs) != 2 {
+		t.Fatalf("diff returned %d differences; want %d: %v", len(diffs), 2, diffs)
+	}
+
+	if d := diffs[0]; d.Key != "aws_cloudwatch_enable" || d.Type != "changed" || d.From != "true" || d.To != "false" {
+		t.Errorf("diffs[0] is %v", d)
+	}
+
+	if d := diffs[0]; len(d.FromSources) != 2 || d.FromSources[0].Level != "Environment" || len(d.ToSources) != 1 || d.ToSources[0].Level != "Common" {
+		t.Errorf("diffs[0] sources are %v and %v", d.FromSources, d.ToSources)
+	}
+
+	if d := diffs[1]; d.Key != "aws_tags.tier" || d.Type != "removed" || d.From != "1" || d.To != "" {
+		t.Errorf("diffs[1] is %v", d)
+	}
+
+	diffs2, err2 := hiera.diff(context.TODO(), nil, "aws_tags", stage, live)
+	if err2 != nil || len(diffs2) != 1 || diffs2[0].Type != "added" {
+		t.Errorf("Error running hiera.diff: %v %s", diffs2, err2)
+	}
+
+	_, err3 := hiera.diff(context.TODO(), nil, "aws_instance_size", stage, live)
+	if err3 == nil {
+		t.Errorf("Error running hiera.diff on a namespace that isn't a hash should return an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if _, ok := compare("k", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`); ok {
+		t.Errorf("hashes differing in key order only should be equal")
+	}
+
+	for _, tc := range [][2]string{{`1.0`, `1`}, {`1e2`, `100`}, {`{"a":[0.50]}`, `{"a":[5e-1]}`}} {
+		if d, ok := compare("k", tc[0], tc[1]); ok {
+			t.Errorf("numbers differing in text only should be equal: %v", d)
+		}
+	}
+
+	if d, ok := compare("k", `9007199254740993`, `9007199254740992`); !ok || d.Type != "changed" {
+		t.Errorf("large integers differing by one should be changed: %v", d)
+	}
+}
+
+func TestHiera5Sensitive(t *testing.T) {
+	var sensitive bool
+
+	hiera := testHiera5Config()
+
+	v, err := hiera.value(context.TODO(), "db_password", WithSensitive(&sensitive))
+	if err != nil || v.ValueString() != "s3cr3t" || !sensitive {
+		t.Errorf("Error running hiera.value on db_password: %s %t %s", v, sensitive, err)
+	}
+
+	_, err = hiera.value(context.TODO(), "aws_instance_size", WithSensitive(&sensitive))
+	if err != nil || sensitive {
+		t.Errorf("Error running hiera.value on aws_instance_size: %t %s", sensitive, err)
+	}
+
+	hiera.SensitiveKeys = []string{"aws_instance_*"}
+
+	_, err = hiera.value(context.TODO(), "aws_instance_size", WithSensitive(&sensitive))
+	if err != nil || !sensitive {
+		t.Errorf("Error running hiera.value on aws_instance_size matching sensitive_keys: %t %s", sensitive, err)
+	}
+
+	sensitive = false
+
+	_, _, err = hiera.matrix(context.TODO(), "db_password", map[string]map[string]interface{}{"api": {}}, WithSensitive(&sensitive))
+	if err != nil || !sensitive {
+		t.Errorf("Error running hiera.matrix on db_password: %t %s", sensitive, err)
+	}
+}
+
+func TestPlainJSON(t *testing.T) {
+	for _, tc := range []struct {
+		value dgo.Value
+		plain string
+		json  string
+	}{
+		{vf.String("t2.large"), "t2.large", `"t2.large"`},
+		{vf.True, "true", `true`},
+		{vf.Integer(1), "1", `1`},
+		{vf.Strings("a"), `["a"]`, `["a"]`},
+		{vf.Map("a", 1), `{"a":1}`, `{"a":1}`},
+		{vf.Binary([]byte("hiera5"), true), "aGllcmE1", `"aGllcmE1"`},
+		{vf.Time(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)), "2024-05-01T10:00:00Z", `"2024-05-01T10:00:00Z"`},
+		{vf.Map("a", vf.Binary([]byte("hiera5"), true)), `{"a":"aGllcmE1"}`, `{"a":"aGllcmE1"}`},
+	} {
+		plain, out, err := plainJSON("key", helper.Result{Value: tc.value, Found: true})
+		if err != nil || plain.ValueString() != tc.plain || out != tc.json {
+			t.Errorf("plainJSON(%v) is %s, %s, %v; want %s, %s", tc.value, plain, out, err, tc.plain, tc.json)
+		}
+	}
+}
+
+func TestHiera5Types(t *testing.T) {
+	var sensitive bool
+
+	hiera := newHiera5("test-fixtures/hiera-types.yaml", map[string]interface{}{"environment": "live"}, "first")
+
+	for key, want := range map[string]string{
+		"tls_certificate": "aGllcmE1IGNlcnRpZmljYXRl",
+		"released_at":     "2024-05-01T10:00:00Z",
+		"large_id":        "9007199254740993",
+	} {
+		v, err := hiera.value(context.TODO(), key)
+		if err != nil || v.ValueString() != want {
+			t.Errorf("%s is %s, %v; want %s", key, v, err, want)
+		}
+	}
+
+	v, err := hiera.value(context.TODO(), "api_key", WithSensitive(&sensitive))
+	if err != nil || v.ValueString() != "k3y" || !sensitive {
+		t.Errorf("Error running hiera.value on api_key: %s %t %v", v, sensitive, err)
+	}
+
+	for key, want := range map[string]string{"large_id": "9007199254740993", "ratio": "0.25"} {
+		result, err := hiera.lookup(context.TODO(), key, "")
+		if err != nil {
+			t.Errorf("Error running hiera on %s: %s", key, err)
+			continue
+		}
+
+		n, ok := numberValue(result.Value)
+		if !ok || n.ValueBigFloat().Text('f', -1) != want {
+			t.Errorf("%s is the number %s; want %s", key, n, want)
+		}
+	}
+}
+
+func TestNumberValue(t *testing.T) {
+	for _, tc := range []struct {
+		value dgo.Value
+		want  string
+	}{
+		{vf.Integer(math.MaxInt64), "9223372036854775807"},
+		{vf.Float(-1.5), "-1.5"},
+		{vf.String(" 12345678901234567890 "), "12345678901234567890"},
+	} {
+		n, ok := numberValue(tc.value)
+		if !ok || n.ValueBigFloat().Text('f', -1) != tc.want {
+			t.Errorf("numberValue(%v) is %s; want %s", tc.value, n, tc.want)
+		}
+	}
+
+	for _, v := range []dgo.Value{vf.String("t2.large"), vf.True, vf.Strings("1")} {
+		if n, ok := numberValue(v); ok {
+			t.Errorf("numberValue(%v) is %s; want no number", v, n)
+		}
+	}
+}
+
+func TestScopeMatrix(t *testing.T) {
+	scopes := scopeMatrix(map[string][]string{
+		"service":     {"api", "worker"},
+		"environment": {"live", "stage"},
+	})
+
+	if len(scopes) != 4 {
+		t.Fatalf("scopeMatrix returned %d scopes; want %d", len(scopes), 4)
+	}
+
+	scope, ok := scopes["environment=stage,service=api"]
+	if !ok || scope["environment"] != "stage" || scope["service"] != "api" || len(scope) != 2 {
+		t.Errorf("scope environment=stage,service=api is %v", scope)
+	}
+
+	if len(scopeMatrix(nil)) != 0 {
+		t.Errorf("scopeMatrix without variables should return no scopes")
+	}
+}
+
 func testHiera5Config() hiera5 {
 	return newHiera5(
 		"test-fixtures/hiera.yaml",
@@ -2,24 +2,110 @@ package hiera5
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	sdk "github.com/lyraproj/hierasdk/hiera"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 )
 
-type Hiera5Provider struct{}
+var _ provider.ProviderWithFunctions = &Hiera5Provider{}
+
+type Hiera5Provider struct {
+	sessions *helper.Sessions
+}
 
 type Hiera5ProviderModel struct {
-	Config types.String      `tfsdk:"config"`
-	Scope  map[string]string `tfsdk:"scope"`
-	Merge  types.String      `tfsdk:"merge"`
+	Config          types.String      `tfsdk:"config"`
+	Scope           map[string]string `tfsdk:"scope"`
+	Merge           types.String      `tfsdk:"merge"`
+	StrictScope     types.Bool        `tfsdk:"strict_scope"`
+	RequiredScope   []string          `tfsdk:"required_scope"`
+	Schemas         map[string]string `tfsdk:"schemas"`
+	SensitiveKeys   []string          `tfsdk:"sensitive_keys"`
+	GitRepo         types.String      `tfsdk:"git_repo"`
+	GitRef          types.String      `tfsdk:"git_ref"`
+	Bundle          types.String      `tfsdk:"bundle"`
+	BundleChecksum  types.String      `tfsdk:"bundle_checksum"`
+	PluginDir       types.String      `tfsdk:"plugin_dir"`
+	PluginTransport types.String      `tfsdk:"plugin_transport"`
 }
 
 func New() provider.Provider {
-	return &Hiera5Provider{}
+	return NewWithOptions()
+}
+
+// Option customizes the provider returned by NewWithOptions
+type Option func(*Hiera5Provider)
+
+// NewWithOptions returns the provider customized by opts, so that it can be
+// served by a provider binary of its own, e.g. with in-process backends
+func NewWithOptions(opts ...Option) provider.Provider {
+	h := &Hiera5Provider{sessions: &helper.Sessions{
+		DataHashes: map[string]sdk.DataHash{},
+		LookupKeys: map[string]sdk.LookupKey{},
+		DataDigs:   map[string]sdk.DataDig{},
+	}}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// WithDataHash makes fn the data_hash backend called name, replacing the
+// backend of that name the provider adds if any. The backends of hiera itself,
+// yaml_data, json_data, environment and scope, can't be replaced.
+func WithDataHash(name string, fn sdk.DataHash) Option {
+	return func(h *Hiera5Provider) {
+		h.forget(name)
+		h.sessions.DataHashes[name] = fn
+	}
+}
+
+// WithLookupKey makes fn the lookup_key backend called name, replacing the
+// backend of that name the provider adds if any. The backends of hiera itself,
+// yaml_data, json_data, environment and scope, can't be replaced.
+func WithLookupKey(name string, fn sdk.LookupKey) Option {
+	return func(h *Hiera5Provider) {
+		h.forget(name)
+		h.sessions.LookupKeys[name] = fn
+	}
+}
+
+// WithDataDig makes fn the data_dig backend called name, replacing the
+// backend of that name the provider adds if any. The backends of hiera itself,
+// yaml_data, json_data, environment and scope, can't be replaced.
+func WithDataDig(name string, fn sdk.DataDig) Option {
+	return func(h *Hiera5Provider) {
+		h.forget(name)
+		h.sessions.DataDigs[name] = fn
+	}
+}
+
+// forget drops the backend called name registered by an earlier option, the
+// last option naming a backend wins
+func (h *Hiera5Provider) forget(name string) {
+	delete(h.sessions.DataHashes, name)
+	delete(h.sessions.LookupKeys, name)
+	delete(h.sessions.DataDigs, name)
+}
+
+// WithScopeProvider adds fn to the lookup_key functions consulted after the
+// hierarchy of the config and the env:: keys, like a last hierarchy level
+// shared by all configs
+func WithScopeProvider(fn sdk.LookupKey) Option {
+	return func(h *Hiera5Provider) {
+		h.sessions.ScopeProviders = append(h.sessions.ScopeProviders, fn)
+	}
 }
 
 func (h *Hiera5Provider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
@@ -35,6 +121,49 @@ func (h *Hiera5Provider) Schema(ctx context.Context, req provider.SchemaRequest,
 				MarkdownDescription: "The merge strategy to use in merging data. Possible values include `first`, `unique`, `hash`, and `deep`. Further documentation can be found [here](https://www.puppet.com/docs/puppet/7/hiera_merging.html). Default: first",
 				Optional:            true,
 			},
+			"strict_scope": schema.BoolAttribute{
+				MarkdownDescription: "Fail lookups when a hierarchy level's paths or options interpolate a scope variable that is not defined, instead of silently interpolating an empty string. Default: false",
+				Optional:            true,
+			},
+			"required_scope": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "List of scope variables that must be defined for lookups to be performed.",
+				Optional:    true,
+			},
+			"schemas": schema.MapAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Map of key patterns to JSON Schemas, given either inline or as a path to a schema file. Values of keys matching a pattern are validated against its schema. Patterns use [shell file name](https://pkg.go.dev/path#Match) syntax, e.g. `aws_*`.",
+				Optional:            true,
+			},
+			"sensitive_keys": schema.ListAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "List of key patterns whose values are sensitive, in the same syntax as `schemas`. Values are also sensitive when `lookup_options` converts them to `Sensitive` or when they come from an encrypted backend. Only `hiera5_sensitive` returns them, the other data sources fail.",
+				Optional:            true,
+			},
+			"git_repo": schema.StringAttribute{
+				MarkdownDescription: "Path of a local git repository to read the hiera config and data files from, as they are in `git_ref` rather than in the working directory. `config` is then relative to the root of the repository.",
+				Optional:            true,
+			},
+			"git_ref": schema.StringAttribute{
+				MarkdownDescription: "The commit, tag or branch of `git_repo` to read, which requires `git_repo` to be set. Default: HEAD",
+				Optional:            true,
+			},
+			"bundle": schema.StringAttribute{
+				MarkdownDescription: "Path of a tar, tar.gz or zip archive to read the hiera config and data files from. `config` is then relative to the root of the archive. Conflicts with `git_repo`.",
+				Optional:            true,
+			},
+			"bundle_checksum": schema.StringAttribute{
+				MarkdownDescription: "The expected SHA-256 digest of `bundle`, hex encoded and optionally prefixed by `sha256:`. Configuration fails when the bundle doesn't match it, or when `bundle` is not set.",
+				Optional:            true,
+			},
+			"plugin_dir": schema.StringAttribute{
+				MarkdownDescription: "The directory of the plugins implementing the backends of hierarchy levels that don't set `plugindir`, relative to the directory of the hiera config. Default: plugin",
+				Optional:            true,
+			},
+			"plugin_transport": schema.StringAttribute{
+				MarkdownDescription: "The transport used to talk to plugins, `unix` or `tcp`. Default: unix, tcp on Windows",
+				Optional:            true,
+			},
 		},
 	}
 }
@@ -56,6 +185,67 @@ func (h *Hiera5Provider) Configure(ctx context.Context, req provider.ConfigureRe
 		data.Config = types.StringValue("hiera.yml")
 	}
 
+	// configID identifies the config read from a copy of a git revision or a
+	// bundle, whose path changes from run to run
+	var configID string
+
+	if !data.GitRef.IsNull() && data.GitRepo.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("git_ref"), "missing attribute", "git_ref requires git_repo to be set")
+	}
+
+	if !data.BundleChecksum.IsNull() && data.Bundle.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("bundle_checksum"), "missing attribute", "bundle_checksum requires bundle to be set")
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.Bundle.IsNull() {
+		if !data.GitRepo.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("bundle"), "conflicting attributes", "bundle and git_repo can't both be set")
+			return
+		}
+
+		tree, digest, err := h.sessions.BundleTree(data.Bundle.ValueString(), data.BundleChecksum.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("bundle"), "unable to read the bundle", err.Error())
+			return
+		}
+
+		config, err := treeConfig(tree, "", data.Config.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("config"), "invalid config", err.Error())
+			return
+		}
+		configID = fmt.Sprintf("%s@sha256:%s", data.Config.ValueString(), digest)
+		data.Config = types.StringValue(config)
+	}
+
+	if !data.GitRepo.IsNull() {
+		if data.GitRef.IsNull() {
+			data.GitRef = types.StringValue("HEAD")
+		}
+
+		config, commit, err := gitConfig(h.sessions, data.GitRepo.ValueString(), data.GitRef.ValueString(), data.Config.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("git_ref"), "unable to read the git revision", err.Error())
+			return
+		}
+		configID = fmt.Sprintf("%s@git:%s", data.Config.ValueString(), commit)
+		data.Config = types.StringValue(config)
+	}
+
+	h.sessions.PluginDir = data.PluginDir.ValueString()
+
+	switch transport := data.PluginTransport.ValueString(); transport {
+	case "", "unix", "tcp":
+		h.sessions.PluginTransport = transport
+	default:
+		resp.Diagnostics.AddAttributeError(path.Root("plugin_transport"), "invalid plugin transport", fmt.Sprintf("%s is not one of unix or tcp", transport))
+		return
+	}
+
 	if data.Merge.IsNull() {
 		data.Merge = types.StringValue("first")
 	}
@@ -70,25 +260,82 @@ func (h *Hiera5Provider) Configure(ctx context.Context, req provider.ConfigureRe
 	}
 
 	client := hiera5{
-		Config: data.Config.ValueString(),
-		Scope:  scope,
-		Merge:  data.Merge.ValueString(),
+		Config:        data.Config.ValueString(),
+		ConfigID:      configID,
+		Scope:         scope,
+		Merge:         data.Merge.ValueString(),
+		StrictScope:   data.StrictScope.ValueBool(),
+		RequiredScope: data.RequiredScope,
+		Schemas:       data.Schemas,
+		SensitiveKeys: data.SensitiveKeys,
+		Sessions:      h.sessions,
 	}
 
 	resp.DataSourceData = client
 	resp.ResourceData = client
 }
 
-func (h *Hiera5Provider) Resources(_ context.Context) []func() resource.Resource {
+// Close stops the plugins started by the lookups of the provider. It's meant to
+// be called once the provider server is done serving.
+func (h *Hiera5Provider) Close() error {
+	h.sessions.Close()
 	return nil
 }
 
+// gitConfig returns the path of config within the files of ref of the git
+// repository repo, exported for the lifetime of sessions, and the hash of the
+// commit ref resolves to
+func gitConfig(sessions *helper.Sessions, repo string, ref string, config string) (string, string, error) {
+	tree, commit, err := sessions.GitTree(repo, ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	config, err = treeConfig(tree, repo, config)
+	return config, commit, err
+}
+
+// treeConfig returns the path of config within tree, the files of root. Absolute
+// config paths must be below root.
+func treeConfig(tree string, root string, config string) (string, error) {
+	if filepath.IsAbs(config) && root != "" {
+		rel, err := filepath.Rel(root, config)
+		if err != nil {
+			return "", err
+		}
+		config = rel
+	}
+
+	if !filepath.IsLocal(config) {
+		return "", fmt.Errorf("config %s is outside of the files read", config)
+	}
+
+	return filepath.Join(tree, config), nil
+}
+
+func (h *Hiera5Provider) Resources(_ context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		NewEyamlEncryptedResource,
+	}
+}
+
 func (h *Hiera5Provider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
 		NewArrayDataSource,
 		NewBoolDataSource,
+		NewNumberDataSource,
 		NewStringDataSource,
 		NewJSONDataSource,
 		NewHashDataSource,
+		NewMatrixDataSource,
+		NewDiffDataSource,
+		NewSensitiveDataSource,
+	}
+}
+
+func (h *Hiera5Provider) Functions(_ context.Context) []func() function.Function {
+	return []func() function.Function{
+		NewEyamlEncryptFunction,
+		NewEyamlDecryptFunction,
 	}
 }
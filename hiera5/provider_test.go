@@ -1,11 +1,20 @@
 package hiera5
 
 import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"testing"
 
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	sdk "github.com/lyraproj/hierasdk/hiera"
 )
 
 const (
@@ -22,8 +31,18 @@ provider "hiera5" {
 `
 )
 
+// testAccProvider serves the acceptance tests, TestMain stops the plugins its
+// lookups started once they are done
+var testAccProvider = New().(*Hiera5Provider)
+
 var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
-	"hiera5": providerserver.NewProtocol6WithError(New()),
+	"hiera5": providerserver.NewProtocol6WithError(testAccProvider),
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = testAccProvider.Close()
+	os.Exit(code)
 }
 
 func TestAccProvider_Basic(t *testing.T) {
@@ -36,3 +55,163 @@ func TestAccProvider_Basic(t *testing.T) {
 		},
 	})
 }
+
+func TestAccProvider_OrphanAttributes(t *testing.T) {
+	for attribute, requires := range map[string]string{"git_ref": "git_repo", "bundle_checksum": "bundle"} {
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			IsUnitTest:               true,
+			Steps: []resource.TestStep{
+				{
+					Config: `
+						provider "hiera5" {
+							config = "test-fixtures/hiera.yaml"
+							` + attribute + ` = "v1.42.0"
+						}
+
+						data "hiera5" "sut" {
+							key = "aws_instance_size"
+						}`,
+					ExpectError: regexp.MustCompile(attribute + " requires " + requires),
+				},
+			},
+		})
+	}
+}
+
+func TestGitConfig(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	sessions := &helper.Sessions{}
+	defer sessions.Close()
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "hiera.yaml"), []byte("version: 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "."}, {"commit", "--quiet", "-m", "init"}} {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	for _, config := range []string{"hiera.yaml", filepath.Join(repo, "hiera.yaml")} {
+		path, commit, err := gitConfig(sessions, repo, "HEAD", config)
+		if err != nil {
+			t.Errorf("Error resolving %s: %s", config, err)
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil || filepath.Dir(path) == repo {
+			t.Errorf("Error %s resolves to %s: %v", config, path, err)
+		}
+
+		// Another run reads the same commit from another copy
+		other := &helper.Sessions{}
+		otherPath, otherCommit, err := gitConfig(other, repo, "HEAD", config)
+		other.Close()
+		if err != nil || otherPath == path || otherCommit != commit || len(commit) != 40 {
+			t.Errorf("Error %s resolves to %s at %s then to %s at %s: %v", config, path, commit, otherPath, otherCommit, err)
+		}
+	}
+
+	if _, _, err := gitConfig(sessions, repo, "HEAD", "../hiera.yaml"); err == nil {
+		t.Errorf("Error a config outside of the repository should fail")
+	}
+}
+
+func TestTreeConfig(t *testing.T) {
+	tree := t.TempDir()
+
+	for _, tc := range []struct {
+		root   string
+		config string
+		want   string
+	}{
+		{"", "hieradata-1.42/hiera.yaml", filepath.Join(tree, "hieradata-1.42", "hiera.yaml")},
+		{"/srv/hieradata", "/srv/hieradata/hiera.yaml", filepath.Join(tree, "hiera.yaml")},
+		{"", "/srv/hieradata/hiera.yaml", ""},
+		{"", "../hiera.yaml", ""},
+	} {
+		config, err := treeConfig(tree, tc.root, tc.config)
+		if config != tc.want || (err != nil) != (tc.want == "") {
+			t.Errorf("Error config %s within %q resolves to %q, %v; want %q", tc.config, tc.root, config, err, tc.want)
+		}
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "hiera.yaml")
+	if err := os.WriteFile(config, []byte("version: 5\nhierarchy:\n  - name: Corp\n    data_hash: corp_data\n  - name: Corp Keys\n    lookup_key: corp_lookup_key\n  - name: Corp Tree\n    data_dig: corp_data_dig\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithOptions(
+		WithDataHash("corp_data", func(sdk.ProviderContext) dgo.Map {
+			return vf.Map("owner", "%{lookup('corp::team')}")
+		}),
+		WithLookupKey("corp_lookup_key", func(_ sdk.ProviderContext, key string) dgo.Value {
+			if key == "cost_center" {
+				return vf.String("cc-42")
+			}
+			return nil
+		}),
+		// The last option naming a backend wins
+		WithLookupKey("corp_data_dig", func(sdk.ProviderContext, string) dgo.Value {
+			return vf.String("replaced")
+		}),
+		WithDataDig("corp_data_dig", func(_ sdk.ProviderContext, key dgo.Array) dgo.Value {
+			if key.Get(0).String() == "network" {
+				return vf.Map("cidr", "10.0.0.0/16")
+			}
+			return nil
+		}),
+		WithScopeProvider(func(_ sdk.ProviderContext, key string) dgo.Value {
+			if key == "corp::team" {
+				return vf.String("platform")
+			}
+			return nil
+		}),
+	).(*Hiera5Provider)
+	defer p.Close()
+
+	hiera := hiera5{Config: config, Scope: map[string]interface{}{}, Merge: "first", Sessions: p.sessions}
+
+	for key, want := range map[string]string{"owner": "platform", "cost_center": "cc-42", "network.cidr": "10.0.0.0/16"} {
+		v, err := hiera.value(context.TODO(), key)
+		if err != nil {
+			t.Errorf("Error looking up %s: %s", key, err)
+		}
+
+		if v.ValueString() != want {
+			t.Errorf("%s is %s; want %s", key, v, want)
+		}
+	}
+}
+
+func TestNewWithOptionsBuiltIn(t *testing.T) {
+	p := NewWithOptions(
+		// Backends of hiera itself are not replaced, those of the provider are
+		WithDataHash("yaml_data", func(sdk.ProviderContext) dgo.Map {
+			return vf.Map("aws_instance_size", "replaced")
+		}),
+		WithLookupKey("vault_lookup_key", func(sdk.ProviderContext, string) dgo.Value {
+			return vf.String("replaced")
+		}),
+	).(*Hiera5Provider)
+	defer p.Close()
+
+	for config, want := range map[string]string{"test-fixtures/hiera.yaml": "t2.large", "test-fixtures/hiera-vault.yaml": "replaced"} {
+		hiera := newHiera5(config, map[string]interface{}{"service": "api", "environment": "live", "facts": "{timezone=>'CET'}"}, "first")
+		hiera.Sessions = p.sessions
+
+		v, err := hiera.value(context.TODO(), "aws_instance_size")
+		if err != nil || v.ValueString() != want {
+			t.Errorf("aws_instance_size in %s is %s; want %s: %v", config, v, want, err)
+		}
+	}
+}
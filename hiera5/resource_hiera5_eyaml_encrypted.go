@@ -0,0 +1,116 @@
+package hiera5
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+var _ resource.Resource = &Hiera5EyamlEncryptedResource{}
+
+// Hiera5EyamlEncryptedResource encrypts a value for hiera-eyaml once and keeps
+// the ciphertext in state, as every encryption draws a new random key
+type Hiera5EyamlEncryptedResource struct{}
+
+type Hiera5EyamlEncryptedResourceModel struct {
+	ID        types.String `tfsdk:"id"`
+	Plaintext types.String `tfsdk:"plaintext"`
+	PublicKey types.String `tfsdk:"public_key"`
+	Value     types.String `tfsdk:"value"`
+}
+
+func NewEyamlEncryptedResource() resource.Resource {
+	return &Hiera5EyamlEncryptedResource{}
+}
+
+func (r *Hiera5EyamlEncryptedResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "hiera5_eyaml_encrypted"
+}
+
+func (r *Hiera5EyamlEncryptedResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Encrypts plaintext with a hiera-eyaml public key into an `ENC[PKCS7,...]` block, ready to be written to an eyaml data file. The block is kept in state and only encrypted again when the plaintext or the public key change.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "SHA-256 digest of the encrypted block.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"plaintext": schema.StringAttribute{
+				Required:    true,
+				Sensitive:   true,
+				Description: "The value to encrypt.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"public_key": schema.StringAttribute{
+				Required:    true,
+				Description: "The PEM encoded public key certificate, or the path to it. Changes to the file a path points to don't replace the resource.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Computed:    true,
+				Description: "The `ENC[PKCS7,...]` block.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *Hiera5EyamlEncryptedResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data Hiera5EyamlEncryptedResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cert, err := readPEMArgument(data.PublicKey.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("public_key"), "unable to read public key", err.Error())
+		return
+	}
+
+	block, err := helper.EyamlEncrypt(data.Plaintext.ValueString(), cert)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("public_key"), "unable to encrypt", err.Error())
+		return
+	}
+
+	digest := sha256.Sum256([]byte(block))
+	data.ID = types.StringValue(hex.EncodeToString(digest[:]))
+	data.Value = types.StringValue(block)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// Read keeps the state as is, the block can't be checked without the private key
+func (r *Hiera5EyamlEncryptedResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+}
+
+// Update is never planned as every configurable attribute requires replacement
+func (r *Hiera5EyamlEncryptedResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data Hiera5EyamlEncryptedResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *Hiera5EyamlEncryptedResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
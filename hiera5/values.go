@@ -0,0 +1,429 @@
+package hiera5
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+
+	"github.com/chriskuchin/terraform-provider-hiera5/hiera5/helper"
+)
+
+// scalarString returns the string form of the scalar v, false when v is an
+// array, a hash or another value that has no plain string form. Binary values
+// are base64 encoded and Timestamps are formatted as RFC 3339.
+func scalarString(v dgo.Value) (string, bool) {
+	switch v := v.(type) {
+	case dgo.String:
+		return v.GoString(), true
+	case dgo.Binary:
+		return v.Encode(), true
+	case dgo.Time:
+		return v.GoTime().Format(time.RFC3339Nano), true
+	case dgo.Boolean:
+		return strconv.FormatBool(v.GoBool()), true
+	case dgo.Integer:
+		return strconv.FormatInt(v.GoInt(), 10), true
+	case dgo.Float:
+		return strconv.FormatFloat(v.GoFloat(), 'f', -1, 64), true
+	}
+
+	if f, ok := bigFloat(v); ok {
+		return f.Text('f', -1), true
+	}
+
+	return "", false
+}
+
+// stringValue returns v as a string attribute, empty when v isn't a scalar
+func stringValue(v dgo.Value) types.String {
+	s, _ := scalarString(v)
+
+	return types.StringValue(s)
+}
+
+// numberValue returns the number v as a number attribute, which keeps integers
+// beyond the precision of a float64 exact. Numeric strings are parsed, other
+// values aren't numbers.
+func numberValue(v dgo.Value) (types.Number, bool) {
+	if f, ok := bigFloat(v); ok {
+		return types.NumberValue(f), true
+	}
+
+	switch v := v.(type) {
+	case dgo.Integer:
+		return types.NumberValue(new(big.Float).SetInt64(v.GoInt())), true
+	case dgo.Float:
+		return types.NumberValue(big.NewFloat(v.GoFloat())), true
+	case dgo.String:
+		f, _, err := big.ParseFloat(strings.TrimSpace(v.GoString()), 10, 512, big.ToNearestEven)
+		if err != nil {
+			return types.NumberNull(), false
+		}
+		return types.NumberValue(f), true
+	default:
+		return types.NumberNull(), false
+	}
+}
+
+// boolValue returns v as a bool attribute, strings are parsed and numbers are
+// true unless zero
+func boolValue(v dgo.Value) types.Bool {
+	switch v := v.(type) {
+	case dgo.Boolean:
+		return types.BoolValue(v.GoBool())
+	case dgo.String:
+		b, _ := strconv.ParseBool(v.GoString())
+		return types.BoolValue(b)
+	case dgo.Integer:
+		return types.BoolValue(v.GoInt() != 0)
+	case dgo.Float:
+		return types.BoolValue(v.GoFloat() != 0)
+	default:
+		return types.BoolValue(false)
+	}
+}
+
+// stringElement returns v as an element of the lists and maps of strings of
+// value, which JSON encodes nested arrays and hashes
+func stringElement(v dgo.Value) (types.String, error) {
+	if s, ok := scalarString(v); ok || v == nil || v.Equals(vf.Nil) {
+		return types.StringValue(s), nil
+	}
+
+	b, err := helper.Result{Found: true, Value: v}.JSON()
+	if err != nil {
+		return types.StringNull(), err
+	}
+
+	return types.StringValue(string(b)), nil
+}
+
+// listValue returns the array v as the list of strings of value, see stringElement
+func listValue(v dgo.Array) (types.List, error) {
+	elements := make([]attr.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		e, err := stringElement(v.Get(i))
+		if err != nil {
+			return types.ListNull(types.StringType), fmt.Errorf("element %d: %w", i, err)
+		}
+		elements = append(elements, e)
+	}
+
+	return types.ListValueMust(types.StringType, elements), nil
+}
+
+// mapValue returns the hash v as the map of strings of value, see stringElement
+func mapValue(v dgo.Map) (types.Map, error) {
+	var err error
+
+	elements := make(map[string]attr.Value, v.Len())
+	v.EachEntry(func(e dgo.MapEntry) {
+		if err != nil {
+			return
+		}
+
+		var ev types.String
+		if ev, err = stringElement(e.Value()); err != nil {
+			err = fmt.Errorf("element %s: %w", e.Key(), err)
+			return
+		}
+		elements[e.Key().String()] = ev
+	})
+	if err != nil {
+		return types.MapNull(types.StringType), err
+	}
+
+	return types.MapValueMust(types.StringType, elements), nil
+}
+
+// elementTypes are the types the elements of the typed_value of an array or a
+// hash can be returned as, dynamic keeps nested arrays and hashes
+var elementTypes = []string{"string", "number", "bool", "dynamic"}
+
+// elementValue returns v as an attribute of elementType, see elementTypes
+func elementValue(v dgo.Value, elementType string) (attr.Value, error) {
+	switch elementType {
+	case "number":
+		n, ok := numberValue(v)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a number", v)
+		}
+		return n, nil
+	case "bool":
+		switch v := v.(type) {
+		case dgo.Boolean:
+			return types.BoolValue(v.GoBool()), nil
+		case dgo.String:
+			if b, err := strconv.ParseBool(v.GoString()); err == nil {
+				return types.BoolValue(b), nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a bool", v)
+	case "dynamic":
+		return dynamicValue(v), nil
+	default:
+		if _, ok := scalarString(v); !ok && v != nil && !v.Equals(vf.Nil) {
+			return nil, fmt.Errorf("%s is not a string, set element_type = \"dynamic\" to keep nested values", v)
+		}
+		return stringValue(v), nil
+	}
+}
+
+// elementAttrType returns the attribute type of the elements of elementType,
+// nil for dynamic whose elements each have their own type
+func elementAttrType(elementType string) attr.Type {
+	switch elementType {
+	case "number":
+		return types.NumberType
+	case "bool":
+		return types.BoolType
+	case "dynamic":
+		return nil
+	default:
+		return types.StringType
+	}
+}
+
+// arrayValue returns the array v as a list of elementType, or as a tuple when
+// elementType is dynamic
+func arrayValue(v dgo.Array, elementType string) (types.Dynamic, error) {
+	elements := make([]attr.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		e, err := elementValue(v.Get(i), elementType)
+		if err != nil {
+			return types.DynamicNull(), fmt.Errorf("element %d: %w", i, err)
+		}
+		elements = append(elements, e)
+	}
+
+	t := elementAttrType(elementType)
+	if t == nil {
+		return types.DynamicValue(tupleValue(elements)), nil
+	}
+
+	return types.DynamicValue(types.ListValueMust(t, elements)), nil
+}
+
+// hashValue returns the hash v as a map of elementType, or as an object when
+// elementType is dynamic
+func hashValue(v dgo.Map, elementType string) (types.Dynamic, error) {
+	var err error
+
+	elements := make(map[string]attr.Value, v.Len())
+	v.EachEntry(func(e dgo.MapEntry) {
+		if err != nil {
+			return
+		}
+
+		var ev attr.Value
+		if ev, err = elementValue(e.Value(), elementType); err != nil {
+			err = fmt.Errorf("element %s: %w", e.Key(), err)
+			return
+		}
+		elements[e.Key().String()] = ev
+	})
+	if err != nil {
+		return types.DynamicNull(), err
+	}
+
+	t := elementAttrType(elementType)
+	if t == nil {
+		return types.DynamicValue(objectValue(elements)), nil
+	}
+
+	return types.DynamicValue(types.MapValueMust(t, elements)), nil
+}
+
+// flattening flattens nested hashes and arrays into a single level hash whose
+// keys are the paths to the values, joined by separator. Array indexes are
+// path elements of their own, or appended in brackets when brackets is set.
+type flattening struct {
+	separator string
+	brackets  bool
+}
+
+// hash returns v flattened, or v itself when f is nil. Empty nested hashes and
+// arrays have no value to keep and are left out.
+func (f *flattening) hash(v dgo.Map) (dgo.Map, error) {
+	if f == nil {
+		return v, nil
+	}
+
+	flat := vf.MapWithCapacity(v.Len())
+	var err error
+	v.EachEntry(func(e dgo.MapEntry) {
+		if err == nil {
+			err = f.flatten(flat, e.Key().String(), e.Value())
+		}
+	})
+
+	return flat, err
+}
+
+func (f *flattening) flatten(flat dgo.Map, path string, v dgo.Value) error {
+	var err error
+
+	switch v := v.(type) {
+	case dgo.Map:
+		v.EachEntry(func(e dgo.MapEntry) {
+			if err == nil {
+				err = f.flatten(flat, path+f.separator+e.Key().String(), e.Value())
+			}
+		})
+	case dgo.Array:
+		for i := 0; i < v.Len() && err == nil; i++ {
+			if f.brackets {
+				err = f.flatten(flat, fmt.Sprintf("%s[%d]", path, i), v.Get(i))
+			} else {
+				err = f.flatten(flat, fmt.Sprintf("%s%s%d", path, f.separator, i), v.Get(i))
+			}
+		}
+	default:
+		if flat.Get(path) != nil {
+			return fmt.Errorf("more than one value flattens to key %s", path)
+		}
+		flat.Put(path, v)
+	}
+
+	return err
+}
+
+// dynamicValue returns v as an attribute of the type closest to it. Arrays
+// become tuples and hashes objects, as their elements may differ in type.
+func dynamicValue(v dgo.Value) attr.Value {
+	if f, ok := bigFloat(v); ok {
+		return types.NumberValue(f)
+	}
+
+	switch v := v.(type) {
+	case dgo.Boolean:
+		return types.BoolValue(v.GoBool())
+	case dgo.Integer, dgo.Float:
+		n, _ := numberValue(v)
+		return n
+	case dgo.Array:
+		elements := make([]attr.Value, 0, v.Len())
+		v.Each(func(e dgo.Value) {
+			elements = append(elements, dynamicValue(e))
+		})
+		return tupleValue(elements)
+	case dgo.Map:
+		elements := make(map[string]attr.Value, v.Len())
+		v.EachEntry(func(e dgo.MapEntry) {
+			elements[e.Key().String()] = dynamicValue(e.Value())
+		})
+		return objectValue(elements)
+	}
+
+	if v == nil || v.Equals(vf.Nil) {
+		return types.StringNull()
+	}
+
+	if s, ok := scalarString(v); ok {
+		return types.StringValue(s)
+	}
+
+	return types.StringValue(v.String())
+}
+
+func tupleValue(elements []attr.Value) types.Tuple {
+	elementTypes := make([]attr.Type, 0, len(elements))
+	for _, e := range elements {
+		elementTypes = append(elementTypes, e.Type(context.Background()))
+	}
+
+	return types.TupleValueMust(elementTypes, elements)
+}
+
+func objectValue(elements map[string]attr.Value) types.Object {
+	attrTypes := make(map[string]attr.Type, len(elements))
+	for k, e := range elements {
+		attrTypes[k] = e.Type(context.Background())
+	}
+
+	return types.ObjectValueMust(attrTypes, elements)
+}
+
+// dgoValue returns the attribute v, typically a default value given in the
+// configuration, as a dgo value so that it is converted like looked up ones
+func dgoValue(v attr.Value) dgo.Value {
+	if v == nil || v.IsNull() || v.IsUnknown() {
+		return vf.Nil
+	}
+
+	switch v := v.(type) {
+	case types.Dynamic:
+		return dgoValue(v.UnderlyingValue())
+	case types.String:
+		return vf.String(v.ValueString())
+	case types.Bool:
+		return vf.Boolean(v.ValueBool())
+	case types.Number:
+		f := v.ValueBigFloat()
+		if i, accuracy := f.Int64(); f.IsInt() && accuracy == big.Exact {
+			return vf.Integer(i)
+		}
+		if f64, accuracy := f.Float64(); accuracy == big.Exact && !f.IsInt() {
+			return vf.Float(f64)
+		}
+		// dgo has no arbitrary precision numbers, see bigFloat
+		return vf.Value(f)
+	case types.List:
+		return dgoArray(v.Elements())
+	case types.Set:
+		return dgoArray(v.Elements())
+	case types.Tuple:
+		return dgoArray(v.Elements())
+	case types.Map:
+		return dgoMap(v.Elements())
+	case types.Object:
+		return dgoMap(v.Attributes())
+	default:
+		return vf.String(v.String())
+	}
+}
+
+// bigFloat returns the number v wraps when it is a number dgoValue couldn't
+// convert to an Integer or a Float without losing precision
+func bigFloat(v dgo.Value) (*big.Float, bool) {
+	if n, ok := v.(dgo.Native); ok {
+		f, ok := n.GoValue().(*big.Float)
+		return f, ok
+	}
+	return nil, false
+}
+
+func dgoArray(elements []attr.Value) dgo.Array {
+	values := make([]dgo.Value, 0, len(elements))
+	for _, e := range elements {
+		values = append(values, dgoValue(e))
+	}
+
+	return vf.WrapSlice(values)
+}
+
+// dgoMap returns elements as a hash whose keys are sorted, so that it encodes
+// the same way every time
+func dgoMap(elements map[string]attr.Value) dgo.Map {
+	keys := make([]string, 0, len(elements))
+	for k := range elements {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := vf.MapWithCapacity(len(elements))
+	for _, k := range keys {
+		m.Put(k, dgoValue(elements[k]))
+	}
+
+	return m
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 
 	"github.com/chriskuchin/terraform-provider-hiera5/hiera5"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 )
 
@@ -19,15 +21,22 @@ import (
 //go:generate go run github.com/hashicorp/terraform-plugin-docs/cmd/tfplugindocs
 
 func main() {
+	p := hiera5.New()
+
 	err := providerserver.Serve(
 		context.Background(),
-		hiera5.New,
+		func() provider.Provider { return p },
 		providerserver.ServeOpts{
 			Address: "registry.terraform.io/chriskuchin/hiera5",
 			// Debug:   true,
 		},
 	)
 
+	// Stop the hiera plugins that lived as long as the provider
+	if c, ok := p.(io.Closer); ok {
+		_ = c.Close()
+	}
+
 	if err != nil {
 		log.Fatal(err)
 	}